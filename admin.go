@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"monkeyy/data"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+const flaggedRunsLimit = 50
+
+type flaggedRunsLoadedMsg struct {
+	runs []data.FlaggedRun
+	err  error
+}
+
+type runReviewedMsg struct {
+	err error
+}
+
+// isAdminIdentity reports whether a session identity belongs to one of the
+// configured admin keys. Anonymous sessions are never admins.
+func isAdminIdentity(identity string, anonymous bool) bool {
+	if anonymous {
+		return false
+	}
+	return slices.Contains(serverConfig.AdminFingerprints, strings.TrimPrefix(identity, "pubkey:"))
+}
+
+func fetchFlaggedRunsCmd() tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in fetchFlaggedRunsCmd", "panic", r)
+			}
+		}()
+
+		runs, err := data.GetFlaggedRuns(flaggedRunsLimit)
+		if err != nil {
+			log.Error("Error fetching flagged runs", "error", err)
+		}
+		return flaggedRunsLoadedMsg{runs: runs, err: err}
+	}
+}
+
+func reviewRunCmd(run data.FlaggedRun, approve bool) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in reviewRunCmd", "panic", r)
+			}
+		}()
+
+		log.Info("Reviewing flagged run", "date_id", run.DateID, "player_id", run.Entry.UserID, "approve", approve)
+		err := data.ReviewRun(run.DateID, run.Entry.UserID, approve)
+		if err != nil {
+			log.Error("Error reviewing run", "error", err)
+		}
+		return runReviewedMsg{err: err}
+	}
+}
+
+func updateAdmin(m model, msg tea.Msg) (model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case flaggedRunsLoadedMsg:
+		m.flaggedRuns = msg.runs
+		if msg.err != nil {
+			m.adminStatus = "Could not load flagged runs"
+		}
+		if m.flaggedCursor >= len(m.flaggedRuns) {
+			m.flaggedCursor = max(0, len(m.flaggedRuns)-1)
+		}
+		return m, nil
+
+	case runReviewedMsg:
+		if msg.err != nil {
+			m.adminStatus = "Review failed: " + msg.err.Error()
+		} else {
+			m.adminStatus = "Review saved"
+		}
+		return m, fetchFlaggedRunsCmd()
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q":
+			m.showingAdmin = false
+			return m, fetchLeaderBoardPage(m)
+		case "up", "k":
+			if m.flaggedCursor > 0 {
+				m.flaggedCursor--
+			}
+		case "down", "j":
+			if m.flaggedCursor < len(m.flaggedRuns)-1 {
+				m.flaggedCursor++
+			}
+		case "a", "x":
+			if m.flaggedCursor < len(m.flaggedRuns) {
+				return m, reviewRunCmd(m.flaggedRuns[m.flaggedCursor], msg.String() == "a")
+			}
+		case "v":
+			if m.flaggedCursor < len(m.flaggedRuns) {
+				run := m.flaggedRuns[m.flaggedCursor]
+				return m, fetchReplayCmd(run.DateID, run.Entry.UserID)
+			}
+		}
+	}
+	return m, nil
+}
+
+func renderAdmin(m model) string {
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Bold(true)
+	rowStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#3b82f6"))
+	detailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	flaggedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#f59e0b"))
+	rejectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444"))
+
+	adminDisplay := []string{titleStyle.Render("🛡  Flagged runs"), ""}
+
+	if len(m.flaggedRuns) == 0 {
+		adminDisplay = append(adminDisplay, detailStyle.Italic(true).Render("   Nothing to review"))
+	}
+	for i, run := range m.flaggedRuns {
+		entry := run.Entry
+		status := flaggedStyle.Render(entry.Status)
+		if entry.Status == data.RunStatusRejected {
+			status = rejectedStyle.Render(entry.Status)
+		}
+
+		line := fmt.Sprintf(" %s  %-20s %4d WPM  %5.1f%%  %s", run.DateID, entry.Username, entry.WPM, entry.Stats.Accuracy, entry.FlagReason)
+		if i == m.flaggedCursor {
+			line = selectedStyle.Render(line)
+		} else {
+			line = rowStyle.Render(line)
+		}
+		adminDisplay = append(adminDisplay, line+"  "+status)
+		if i == m.flaggedCursor && entry.FlagDetail != "" {
+			adminDisplay = append(adminDisplay, detailStyle.Render("     "+entry.FlagDetail))
+		}
+	}
+
+	adminDisplay = append(adminDisplay, "")
+	if m.adminStatus != "" {
+		adminDisplay = append(adminDisplay, detailStyle.Render(m.adminStatus))
+	}
+	adminDisplay = append(adminDisplay, detailStyle.Render("↑ ↓: select | a: approve | x: reject | v: watch replay | esc: back"))
+
+	return lipgloss.JoinVertical(lipgloss.Left, adminDisplay...)
+}
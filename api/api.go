@@ -0,0 +1,197 @@
+// Package api serves the leaderboards and player results as read-only JSON
+// over HTTP, for dashboards and bots that can't play over SSH. Responses
+// carry an ETag and a Cache-Control max-age, so clients polling for changes
+// mostly get 304 Not Modified back.
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"monkeyy/data"
+)
+
+// How long clients may cache each kind of response. Today's results change
+// as players finish, past days only when an admin reviews a flagged run.
+const (
+	liveMaxAge = 10 * time.Second
+	pastMaxAge = 5 * time.Minute
+)
+
+// Limits for the ?limit= parameter of list endpoints. A player's history
+// is capped lower, since ranking each day reads that day's whole board.
+const (
+	defaultBoardLimit   = 100
+	defaultHistoryLimit = 30
+	maxLimit            = 1000
+	maxHistoryLimit     = data.MaxHistoryResults
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler returns the API's routes:
+//
+//	GET /api/leaderboard              today's leaderboard
+//	GET /api/leaderboard/{date}       the leaderboard of a past day
+//	GET /api/boards/{board}           an aggregate board, e.g. weekly
+//	GET /api/players/{id}             a player's profile and daily history
+//	GET /api/sentence                 today's challenge, without its text
+//	GET /api/stats                    totals across every player and day
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/leaderboard", handleLeaderboard)
+	mux.HandleFunc("GET /api/leaderboard/{date}", handleLeaderboard)
+	mux.HandleFunc("GET /api/boards/{board}", handleBoard)
+	mux.HandleFunc("GET /api/players/{id}", handlePlayer)
+	mux.HandleFunc("GET /api/sentence", handleSentence)
+	mux.HandleFunc("GET /api/stats", handleStats)
+	return mux
+}
+
+func handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	today := data.TodayID()
+	dateID := r.PathValue("date")
+	if dateID == "" {
+		dateID = today
+	}
+	if _, err := data.ParseDateID(dateID); err != nil {
+		writeError(w, http.StatusBadRequest, "dates look like 2006-01-02")
+		return
+	}
+	board, err := data.GetLeaderBoardForDate(dateID)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	board.Redact()
+
+	maxAge := pastMaxAge
+	if dateID >= today {
+		maxAge = liveMaxAge
+	}
+	writeJSON(w, r, maxAge, board)
+}
+
+func handleBoard(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseLimit(w, r, defaultBoardLimit, maxLimit)
+	if !ok {
+		return
+	}
+	entries, err := data.GetAggregateBoard(r.PathValue("board"), limit)
+	if errors.Is(err, data.ErrUnknownBoard) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	writeJSON(w, r, liveMaxAge, entries)
+}
+
+func handlePlayer(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseLimit(w, r, defaultHistoryLimit, maxHistoryLimit)
+	if !ok {
+		return
+	}
+	profile, err := data.GetPlayerProfile(r.PathValue("id"), limit)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	if profile.DaysPlayed == 0 && len(profile.Results) == 0 {
+		writeError(w, http.StatusNotFound, "no results for that player")
+		return
+	}
+	writeJSON(w, r, liveMaxAge, profile)
+}
+
+func handleSentence(w http.ResponseWriter, r *http.Request) {
+	info, err := data.GetTodaysSentenceInfo()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	writeJSON(w, r, liveMaxAge, info)
+}
+
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := data.GetServerStats()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	writeJSON(w, r, liveMaxAge, stats)
+}
+
+// parseLimit reads the ?limit= parameter, answering with a 400 itself if it
+// isn't a number between 1 and max.
+func parseLimit(w http.ResponseWriter, r *http.Request, fallback int, max int) (int, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return fallback, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > max {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", max))
+		return 0, false
+	}
+	return limit, true
+}
+
+// writeJSON sends value with an ETag of its encoding, or just 304 Not
+// Modified when the client already has it.
+func writeJSON(w http.ResponseWriter, r *http.Request, maxAge time.Duration, value any) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// validators match too, as they do for GET requests.
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	body, _ := json.Marshal(errorResponse{Error: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+func serverError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Error("HTTP API request failed", "error", err, "path", r.URL.Path)
+	writeError(w, http.StatusInternalServerError, "something went wrong")
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"monkeyy/data"
+	"monkeyy/typing"
+)
+
+// openStore opens a fresh database with today's challenge in it, and one
+// run whose evenly spaced keys get it flagged.
+func openStore(t *testing.T) {
+	t.Helper()
+	data.InitInMemoryStore(t.TempDir())
+	t.Cleanup(data.Shutdown)
+
+	sentence, err := data.GetTodaysSentence()
+	if err != nil {
+		t.Fatalf("GetTodaysSentence: %v", err)
+	}
+	keystrokes := []data.Keystroke{}
+	for i, key := range typing.Graphemes(sentence) {
+		keystrokes = append(keystrokes, data.Keystroke{OffsetMillis: int64(i) * 200, Key: key})
+	}
+	if err := data.SubmitSentence(context.Background(), data.TodayID(), time.Now(), "player-1", "alice", data.RunStats{}, keystrokes); err != nil {
+		t.Fatalf("SubmitSentence: %v", err)
+	}
+}
+
+func get(t *testing.T, handler http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLeaderboard(t *testing.T) {
+	openStore(t)
+	handler := NewHandler()
+
+	rec := get(t, handler, "/api/leaderboard", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /api/leaderboard = %d %s", rec.Code, rec.Body)
+	}
+	var board data.LeaderBoardResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &board); err != nil {
+		t.Fatalf("decoding leaderboard: %v", err)
+	}
+	if board.DateID != data.TodayID() || len(board.LeaderboardEntries) != 1 || board.LeaderboardEntries[0].Username != "alice" {
+		t.Fatalf("leaderboard = %+v", board)
+	}
+	if board.Sentence != "" {
+		t.Error("today's leaderboard gives the text away")
+	}
+	if entry := board.LeaderboardEntries[0]; entry.Status != data.RunStatusFlagged || entry.FlagReason != "" || entry.FlagDetail != "" {
+		t.Errorf("flagged entry = %+v, want the status without its details", entry)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" || !strings.Contains(rec.Header().Get("Cache-Control"), "max-age=") {
+		t.Fatalf("missing caching headers: %v", rec.Header())
+	}
+	rec = get(t, handler, "/api/leaderboard", http.Header{"If-None-Match": {`"other", W/` + etag}})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match = %d with %d bytes, want an empty 304", rec.Code, rec.Body.Len())
+	}
+
+	rec = get(t, handler, "/api/leaderboard/"+data.TodayID(), nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != etag {
+		t.Errorf("today by date = %d, ETag %s, want the same response as /api/leaderboard", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestRequests(t *testing.T) {
+	openStore(t)
+	handler := NewHandler()
+
+	tests := []struct {
+		path string
+		code int
+		want string
+	}{
+		{"/api/leaderboard/2020-01-01", http.StatusOK, `"leaderboard_entries":[]`},
+		{"/api/leaderboard/yesterday", http.StatusBadRequest, "2006-01-02"},
+		{"/api/boards/all-time", http.StatusOK, `"username":"alice"`},
+		{"/api/boards/all-time?limit=0", http.StatusBadRequest, "limit"},
+		{"/api/boards/fastest", http.StatusNotFound, "unknown leaderboard"},
+		{"/api/players/player-1", http.StatusOK, `"days_played":1`},
+		{"/api/players/nobody", http.StatusNotFound, "no results"},
+		{"/api/players/player-1?limit=1000", http.StatusBadRequest, "between 1 and 60"},
+		{"/api/sentence", http.StatusOK, `"players":1`},
+		{"/api/stats", http.StatusOK, `"today_players":1`},
+	}
+	for _, tt := range tests {
+		rec := get(t, handler, tt.path, nil)
+		if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("GET %s = %d %s, want %d containing %s", tt.path, rec.Code, rec.Body, tt.code, tt.want)
+		}
+	}
+
+	sentence, _ := data.GetTodaysSentence()
+	if rec := get(t, handler, "/api/sentence", nil); strings.Contains(rec.Body.String(), sentence) {
+		t.Error("sentence metadata gives the text away")
+	}
+}
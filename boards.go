@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"monkeyy/data"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+// boardTab is one of the leaderboard tabs. The daily tab shows a single
+// day's rankings; the others show a data aggregate board.
+type boardTab struct {
+	Name  string
+	Board string
+	Title string
+}
+
+var boardTabs = []boardTab{
+	{Name: "Daily"},
+	{Name: "Weekly", Board: data.BoardWeekly, Title: fmt.Sprintf("Weekly - average of the last 7 days (%d+ days played)", data.WeeklyMinDays)},
+	{Name: "Monthly", Board: data.BoardMonthly, Title: fmt.Sprintf("Monthly - average of the last 30 days (%d+ days played)", data.MonthlyMinDays)},
+	{Name: "All-time", Board: data.BoardAllTime, Title: "All-time - best single day"},
+	{Name: "Streaks", Board: data.BoardStreaks, Title: "Streaks - consecutive days played"},
+}
+
+type aggregateBoardMsg struct {
+	board  string
+	offset int
+	page   *data.AggregatePage
+}
+
+// fetchAggregateBoardCmd loads a page of an aggregate board. Ranking them
+// reads every player's aggregate, so they aren't polled like the daily
+// board: they load when their tab is opened, paged or refreshed.
+func fetchAggregateBoardCmd(board string, playerID string, offset int, limit int) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in fetchAggregateBoardCmd", "panic", r, "board", board)
+			}
+		}()
+
+		log.Debug("Fetching aggregate leaderboard", "board", board, "offset", offset)
+		page, err := data.GetAggregateBoardPage(board, playerID, offset, limit)
+		if err != nil {
+			log.Error("Error fetching aggregate leaderboard", "error", err, "board", board)
+			page = &data.AggregatePage{Board: board, Entries: []data.AggregateEntry{}, OwnRow: -1}
+		}
+		return aggregateBoardMsg{board: board, offset: offset, page: page}
+	}
+}
+
+// fetchAggregatePage loads the page of the aggregate board the player is
+// looking at.
+func fetchAggregatePage(m model) tea.Cmd {
+	return fetchAggregateBoardCmd(m.aggregateBoard(), m.playerID, m.currentPage*m.entriesPerPage, m.entriesPerPage)
+}
+
+// aggregateBoard is the data board behind the current tab, or "" on the
+// daily tab. The all-time tab can be ordered by days played instead.
+func (m model) aggregateBoard() string {
+	board := boardTabs[m.boardTab].Board
+	if board == data.BoardAllTime && m.orderByDaysPlayed {
+		return data.BoardMostPlayed
+	}
+	return board
+}
+
+// boardLen is the number of rows on the current tab. Tabs only hold the
+// page on screen, so this counts the whole board.
+func (m model) boardLen() int {
+	if m.aggregateBoard() == "" {
+		return m.boardTotal
+	}
+	return m.aggregateTotal
+}
+
+// loadedRows is the range of rows of the current tab's page last fetched.
+func (m model) loadedRows() (int, int) {
+	if m.aggregateBoard() == "" {
+		return m.boardOffset, m.boardOffset + len(m.LeaderboardEntries)
+	}
+	return m.aggregateOffset, m.aggregateOffset + len(m.aggregateEntries)
+}
+
+// showPage moves the current tab to page and fetches it.
+func showPage(m model, page int) (model, tea.Cmd) {
+	if page == m.currentPage {
+		return m, nil
+	}
+	m.currentPage = page
+	if m.aggregateBoard() == "" {
+		return m, fetchLeaderBoardPage(m)
+	}
+	return m, fetchAggregatePage(m)
+}
+
+func switchBoardTab(m model, tab int) (model, tea.Cmd) {
+	m.boardTab = (tab + len(boardTabs)) % len(boardTabs)
+	m.currentPage = 0
+	m.aggregateEntries = nil
+	m.aggregateOffset = 0
+	m.aggregateTotal = 0
+	m.aggregateOwn = nil
+	if m.aggregateBoard() != "" {
+		return m, fetchAggregatePage(m)
+	}
+	return m, fetchLeaderBoardPage(m)
+}
+
+// updateAggregateBoard applies a fetched page of an aggregate board, unless
+// the player has moved to another board or page since.
+func updateAggregateBoard(m model, msg aggregateBoardMsg) model {
+	if msg.board != m.aggregateBoard() || msg.offset != m.currentPage*m.entriesPerPage {
+		return m
+	}
+	m.aggregateEntries = msg.page.Entries
+	m.aggregateOffset = msg.offset
+	m.aggregateTotal = msg.page.Total
+	m.aggregateOwn = msg.page.Own
+	m.aggregateOwnRow = msg.page.OwnRow
+	return m
+}
+
+func renderBoardTabs(m model) string {
+	activeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#3b82f6")).Padding(0, 1)
+	inactiveStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Padding(0, 1)
+
+	tabs := []string{}
+	for i, tab := range boardTabs {
+		if i == m.boardTab {
+			tabs = append(tabs, activeStyle.Render(tab.Name))
+		} else {
+			tabs = append(tabs, inactiveStyle.Render(tab.Name))
+		}
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Left, tabs...)
+}
+
+// boardEntryText describes row i of the current tab, without its position.
+func boardEntryText(m model, i int) string {
+	if m.aggregateBoard() == "" {
+		entry := m.LeaderboardEntries[i-m.boardOffset]
+		text := fmt.Sprintf("%s%s: %d WPM (%.0f%% acc)", entry.Username, streakText(entry.Streak), entry.WPM, entry.Accuracy)
+		if entry.Flagged {
+			text += " ⚠ under review"
+		}
+		return text
+	}
+
+	entry := m.aggregateEntries[i-m.aggregateOffset]
+	switch m.aggregateBoard() {
+	case data.BoardAllTime:
+		return fmt.Sprintf("%s%s: %.0f WPM on %s (%d days played)", entry.Username, streakText(entry.Streak), entry.WPM, entry.DateID, entry.DaysPlayed)
+	case data.BoardMostPlayed:
+		return fmt.Sprintf("%s%s: %d days played (best %.0f WPM)", entry.Username, streakText(entry.Streak), entry.DaysPlayed, entry.WPM)
+	case data.BoardStreaks:
+		return fmt.Sprintf("%s: %d days in a row (longest %d)", entry.Username, entry.Streak.Current, entry.Streak.Longest)
+	default:
+		return fmt.Sprintf("%s%s: %.1f WPM over %d days", entry.Username, streakText(entry.Streak), entry.WPM, entry.DaysPlayed)
+	}
+}
+
+// ownRow is the index of the player's row on the current tab, or -1 if they
+// aren't on it.
+func (m model) ownRow() int {
+	if m.aggregateBoard() == "" {
+		if m.ownEntry == nil {
+			return -1
+		}
+		return m.ownBoardRow
+	}
+	if m.aggregateOwn == nil {
+		return -1
+	}
+	return m.aggregateOwnRow
+}
+
+// boardRank is the rank shown for row i. The daily board uses the ranks
+// computed by the server, where tied players share a rank.
+func (m model) boardRank(i int) int {
+	if m.aggregateBoard() == "" {
+		if i == m.ownRow() && m.ownEntry.Rank > 0 {
+			return m.ownEntry.Rank
+		}
+		if entry := m.LeaderboardEntries[i-m.boardOffset]; entry.Rank > 0 {
+			return entry.Rank
+		}
+	}
+	return i + 1
+}
+
+// ownRankText is the line pinned under every page saying where the player
+// stands on the current tab.
+func ownRankText(m model) string {
+	row := m.ownRow()
+	if row < 0 {
+		if m.aggregateBoard() == "" {
+			return "You: not on this board"
+		}
+		return "You: not ranked here yet"
+	}
+
+	score := ""
+	if m.aggregateBoard() == "" {
+		score = fmt.Sprintf("%d WPM", m.ownEntry.WPM)
+	} else {
+		entry := m.aggregateOwn
+		switch m.aggregateBoard() {
+		case data.BoardMostPlayed:
+			score = fmt.Sprintf("%d days played", entry.DaysPlayed)
+		case data.BoardStreaks:
+			score = fmt.Sprintf("%d days in a row", entry.Streak.Current)
+		case data.BoardAllTime:
+			score = fmt.Sprintf("%.0f WPM", entry.WPM)
+		default:
+			score = fmt.Sprintf("%.1f WPM", entry.WPM)
+		}
+	}
+	return fmt.Sprintf("You: #%d of %d — %s", m.boardRank(row), m.boardLen(), score)
+}
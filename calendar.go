@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"monkeyy/data"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+// calendarState is the date picker opened from the leaderboard. Days are
+// held as midnight UTC, the way data.ParseDateID returns them.
+type calendarState struct {
+	cursor time.Time
+	today  time.Time
+	played map[string]bool
+}
+
+type challengeDatesMsg struct {
+	dates []string
+}
+
+func fetchChallengeDatesCmd() tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in fetchChallengeDatesCmd", "panic", r)
+			}
+		}()
+
+		dates, err := data.GetChallengeDates()
+		if err != nil {
+			log.Error("Error fetching challenge dates", "error", err)
+		}
+		return challengeDatesMsg{dates: dates}
+	}
+}
+
+func newCalendarState(selected string) *calendarState {
+	today, _ := data.ParseDateID(data.TodayID())
+	cursor, err := data.ParseDateID(selected)
+	if err != nil {
+		cursor = today
+	}
+	return &calendarState{cursor: cursor, today: today, played: map[string]bool{}}
+}
+
+// move shifts the cursor by days, never past today.
+func (c *calendarState) move(days int) {
+	c.cursor = c.cursor.AddDate(0, 0, days)
+	if c.cursor.After(c.today) {
+		c.cursor = c.today
+	}
+}
+
+// moveMonth shifts the cursor by months, keeping the day of the month where
+// the new month is long enough.
+func (c *calendarState) moveMonth(months int) {
+	first := time.Date(c.cursor.Year(), c.cursor.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	c.cursor = first.AddDate(0, 0, min(c.cursor.Day(), lastDay)-1)
+	if c.cursor.After(c.today) {
+		c.cursor = c.today
+	}
+}
+
+func updateCalendar(m model, msg tea.Msg) (model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case challengeDatesMsg:
+		for _, dateID := range msg.dates {
+			m.calendar.played[dateID] = true
+		}
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q":
+			m.calendar = nil
+		case "left", "h":
+			m.calendar.move(-1)
+		case "right", "l":
+			m.calendar.move(1)
+		case "up", "k":
+			m.calendar.move(-7)
+		case "down", "j":
+			m.calendar.move(7)
+		case "[", "pgup":
+			m.calendar.moveMonth(-1)
+		case "]", "pgdown":
+			m.calendar.moveMonth(1)
+		case "enter":
+			dateID := m.calendar.cursor.Format("2006-01-02")
+			m.calendar = nil
+			return showLeaderboardDate(m, dateID)
+		}
+	}
+	return m, nil
+}
+
+// showLeaderboardDate switches the leaderboard to another day. Today is kept
+// as the empty date so the board follows the daily reset.
+func showLeaderboardDate(m model, dateID string) (model, tea.Cmd) {
+	if dateID >= data.TodayID() {
+		dateID = ""
+	}
+	m.viewDateID = dateID
+	m.currentPage = 0
+	return m, fetchLeaderBoardPage(m)
+}
+
+func renderCalendar(m model) string {
+	c := m.calendar
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Bold(true)
+	playedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
+	emptyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#555555"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#3b82f6"))
+	todayStyle := playedStyle.Underline(true)
+	controlsStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+
+	first := time.Date(c.cursor.Year(), c.cursor.Month(), 1, 0, 0, 0, 0, time.UTC)
+	calendarDisplay := []string{
+		titleStyle.Render("📅 " + first.Format("January 2006")),
+		"",
+		controlsStyle.Render("Mo Tu We Th Fr Sa Su"),
+	}
+
+	// weeks start on Monday
+	offset := (int(first.Weekday()) + 6) % 7
+	var week strings.Builder
+	week.WriteString(strings.Repeat("   ", offset))
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		dateID := day.Format("2006-01-02")
+		style := emptyStyle
+		if c.played[dateID] {
+			style = playedStyle
+		}
+		if day.Equal(c.today) {
+			style = todayStyle
+		}
+		if day.Equal(c.cursor) {
+			style = selectedStyle
+		}
+		week.WriteString(style.Render(fmt.Sprintf("%2d", day.Day())) + " ")
+
+		if day.Weekday() == time.Sunday {
+			calendarDisplay = append(calendarDisplay, week.String())
+			week.Reset()
+		}
+	}
+	if week.Len() > 0 {
+		calendarDisplay = append(calendarDisplay, week.String())
+	}
+
+	calendarDisplay = append(calendarDisplay,
+		"",
+		controlsStyle.Render("←↑↓→: move | [ ]: month | enter: show that day | esc: back"),
+	)
+	return lipgloss.JoinVertical(lipgloss.Left, calendarDisplay...)
+}
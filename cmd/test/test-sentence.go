@@ -1,26 +1,41 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"os"
+
+	"monkeyy/config"
 	"monkeyy/data"
 )
 
-
+// Prints the daily challenge text derived for a date, e.g. to audit a past
+// day's challenge. Takes the same config flags, config file and environment
+// as the server, so the seed, word count and challenge clock match, and the
+// default date is the server's current challenge day. Days played before
+// word_count was changed need their own count passed with -words.
 func main() {
-	// The data package now uses an in-memory store, so no database is needed.
-
-	sentence, err := data.GetLongSentence()
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	date := fs.String("date", "", "challenge date (YYYY-MM-DD), today's challenge day by default")
+	words := fs.Int("words", 0, "number of words the day's text was derived with, word_count by default")
+	cfg, err := config.LoadFlags(fs, os.Args[1:])
 	if err != nil {
-		log.Fatal(err)
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
+	clock, _ := cfg.ChallengeClock()
+	data.SetChallengeClock(clock)
+	data.SetChallengeSeed(cfg.ChallengeSeed)
 
-	fmt.Println("Inserting sentence: ", sentence)
-	err = data.InsertSentence(sentence)
-	if err != nil {
-		log.Fatal(err)
+	if *date == "" {
+		*date = data.TodayID()
+	}
+	if *words == 0 {
+		*words = cfg.WordCount
 	}
-	
-	fmt.Println("Sentence inserted successfully")
-	
-}
\ No newline at end of file
+	fmt.Println(data.DailySentence(*date, *words))
+}
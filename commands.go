@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkeyy/data"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+const commandUsage = `Usage: ssh <host> <command> [--json]
+
+Commands:
+  leaderboard [date]  today's leaderboard, or a past day's (2006-01-02)
+  me                  your profile and streak
+  history             your recent daily results, newest first
+  sentence            today's challenge, with its text once you've played it
+  stats               totals across every player and day
+
+To play, connect with a terminal: ssh -t <host>
+`
+
+var errUsage = errors.New("usage")
+
+// noProfileText answers the commands about the caller's results for
+// callers who have never played.
+const noProfileText = "No profile yet. Play with: ssh -t <host>\n"
+
+// commandMiddleware answers sessions without a terminal, which can't play,
+// by running the command they were started with and exiting. Sessions with
+// a terminal go on to the game.
+func commandMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if _, _, active := s.Pty(); active {
+				next(s)
+				return
+			}
+			// commands only read, so they don't register new players
+			identity, _ := sessionIdentity(s)
+			player := func() (string, error) {
+				return data.LookupPlayerID(identity)
+			}
+			_ = s.Exit(runCommand(s.Command(), player, s, s.Stderr()))
+		}
+	}
+}
+
+// runCommand runs a non-interactive command, writing its output to out as
+// plain text or, with --json, as JSON. player looks up the caller's player
+// ID for the commands that need it, returning data.ErrNoPlayer for callers
+// who have never played. It returns the exit status.
+func runCommand(args []string, player func() (string, error), out io.Writer, errOut io.Writer) int {
+	asJSON := slices.Contains(args, "--json")
+	args = slices.DeleteFunc(slices.Clone(args), func(arg string) bool { return arg == "--json" })
+	if len(args) == 0 {
+		fmt.Fprint(errOut, commandUsage)
+		return 1
+	}
+
+	var result any
+	var text string
+	var err error
+	switch args[0] {
+	case "help":
+		fmt.Fprint(out, commandUsage)
+		return 0
+	case "leaderboard":
+		result, text, err = leaderboardCommand(args[1:])
+	case "me":
+		result, text, err = meCommand(args[1:], player)
+	case "history":
+		result, text, err = historyCommand(args[1:], player)
+	case "sentence":
+		result, text, err = sentenceCommand(args[1:], player)
+	case "stats":
+		result, text, err = statsCommand(args[1:])
+	case "race":
+		err = errors.New("racing needs a terminal, connect with: ssh -t <host> race")
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(errOut, commandUsage)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(errOut, "error:", err)
+		return 1
+	}
+	if asJSON {
+		if err := json.NewEncoder(out).Encode(result); err != nil {
+			log.Error("Could not write command output", "error", err, "command", args[0])
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprint(out, text)
+	return 0
+}
+
+func leaderboardCommand(args []string) (any, string, error) {
+	if len(args) > 1 {
+		return nil, "", errUsage
+	}
+	dateID := data.TodayID()
+	if len(args) == 1 {
+		dateID = args[0]
+	}
+	if _, err := data.ParseDateID(dateID); err != nil {
+		return nil, "", fmt.Errorf("%q is not a date like 2006-01-02", dateID)
+	}
+	board, err := data.GetLeaderBoardForDate(dateID)
+	if err != nil {
+		return nil, "", err
+	}
+	board.Redact()
+
+	text := fmt.Sprintf("Leaderboard %s: %d players\n", board.DateID, len(board.LeaderboardEntries))
+	for _, entry := range board.LeaderboardEntries {
+		text += fmt.Sprintf("%4s  %-20s %4d WPM  %5.1f%%", fmt.Sprintf("#%d", entry.Rank), entry.Username, entry.WPM, entry.Stats.Accuracy)
+		if entry.Status == data.RunStatusFlagged {
+			text += "  (under review)"
+		}
+		text += "\n"
+	}
+	return board, text, nil
+}
+
+// meResult is the me command's JSON output: the caller's profile with
+// their streak.
+type meResult struct {
+	*data.PlayerProfile
+	Streak data.Streak `json:"streak"`
+}
+
+func meCommand(args []string, player func() (string, error)) (any, string, error) {
+	if len(args) > 0 {
+		return nil, "", errUsage
+	}
+	playerID, err := player()
+	if errors.Is(err, data.ErrNoPlayer) {
+		return meResult{PlayerProfile: &data.PlayerProfile{Results: []data.PlayerResult{}}}, noProfileText, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	profile, err := data.GetPlayerProfile(playerID, 1)
+	if err != nil {
+		return nil, "", err
+	}
+	streak, err := data.GetStreak(playerID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := meResult{PlayerProfile: profile, Streak: streak}
+	if profile.DaysPlayed == 0 {
+		return result, "You haven't got a daily challenge result yet. Play with: ssh -t <host>\n", nil
+	}
+	text := fmt.Sprintf("%s\n", profile.Username)
+	text += fmt.Sprintf("Days played  %d\n", profile.DaysPlayed)
+	text += fmt.Sprintf("Best         %d WPM on %s\n", profile.BestWPM, profile.BestDateID)
+	text += fmt.Sprintf("Average      %.1f WPM\n", profile.AverageWPM)
+	text += fmt.Sprintf("Faster than  %.0f%% of players\n", profile.Percentile)
+	text += fmt.Sprintf("Streak       %d days (longest %d)\n", streak.Current, streak.Longest)
+	if len(profile.Results) > 0 && profile.Results[0].DateID == data.TodayID() {
+		text += fmt.Sprintf("Today        %s\n", resultText(profile.Results[0]))
+	}
+	return result, text, nil
+}
+
+func historyCommand(args []string, player func() (string, error)) (any, string, error) {
+	if len(args) > 0 {
+		return nil, "", errUsage
+	}
+	playerID, err := player()
+	if errors.Is(err, data.ErrNoPlayer) {
+		return []data.PlayerResult{}, noProfileText, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	results, err := data.GetPlayerHistory(playerID, data.MaxHistoryResults)
+	if err != nil {
+		return nil, "", err
+	}
+
+	text := ""
+	for _, result := range results {
+		text += fmt.Sprintf("%s  %s\n", result.DateID, resultText(result))
+	}
+	if text == "" {
+		text = "You haven't played the daily challenge yet.\n"
+	}
+	return results, text, nil
+}
+
+// resultText is one day's result, with its rank if it made the leaderboard.
+func resultText(result data.PlayerResult) string {
+	text := fmt.Sprintf("%4d WPM  %5.1f%%", result.WPM, result.Accuracy)
+	switch {
+	case result.Rank > 0:
+		text += fmt.Sprintf("  #%d of %d", result.Rank, result.Total)
+	case result.Status != "":
+		text += "  " + result.Status
+	}
+	return text
+}
+
+// sentenceResult is the sentence command's JSON output. Text is only
+// filled in for players who have played today's challenge.
+type sentenceResult struct {
+	*data.SentenceInfo
+	Text string `json:"text,omitempty"`
+}
+
+func sentenceCommand(args []string, player func() (string, error)) (any, string, error) {
+	if len(args) > 0 {
+		return nil, "", errUsage
+	}
+	info, err := data.GetTodaysSentenceInfo()
+	if err != nil {
+		return nil, "", err
+	}
+	result := sentenceResult{SentenceInfo: info}
+	played := false
+	playerID, err := player()
+	if err != nil && !errors.Is(err, data.ErrNoPlayer) {
+		return nil, "", err
+	}
+	if err == nil {
+		if played, err = data.GetUserChallengeStatus(playerID); err != nil {
+			return nil, "", err
+		}
+	}
+	if played {
+		if result.Text, err = data.GetTodaysSentence(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	text := fmt.Sprintf("Challenge %s: %d words, %d characters, %d players so far\n", info.DateID, info.Words, info.Characters, info.Players)
+	text += fmt.Sprintf("Next challenge in %s\n", formatDuration(time.Until(info.ResetsAt)))
+	if result.Text != "" {
+		text += "\n" + result.Text + "\n"
+	}
+	return result, text, nil
+}
+
+func statsCommand(args []string) (any, string, error) {
+	if len(args) > 0 {
+		return nil, "", errUsage
+	}
+	stats, err := data.GetServerStats()
+	if err != nil {
+		return nil, "", err
+	}
+
+	text := fmt.Sprintf("Players         %d\n", stats.Players)
+	text += fmt.Sprintf("Daily runs      %d\n", stats.Runs)
+	text += fmt.Sprintf("Average         %.1f WPM\n", stats.AverageWPM)
+	if stats.BestUsername != "" {
+		text += fmt.Sprintf("Best            %d WPM by %s on %s\n", stats.BestWPM, stats.BestUsername, stats.BestDateID)
+	}
+	text += fmt.Sprintf("Longest streak  %d days\n", stats.LongestStreak)
+	text += fmt.Sprintf("Today           %d players, %.1f WPM average\n", stats.TodayPlayers, stats.TodayAverageWPM)
+	return stats, text, nil
+}
@@ -0,0 +1,255 @@
+// Package config loads the server configuration. Values come from built-in
+// defaults, then an optional TOML file, then environment variables, then
+// command line flags, each overriding the last.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/log"
+
+	"monkeyy/data"
+)
+
+type Config struct {
+	ListenAddress  string   `toml:"listen_address"`
+	DBPath         string   `toml:"db_path"`
+	HostKeyPaths   []string `toml:"host_key_paths"`
+	Timezone       string   `toml:"timezone"`
+	ResetTime      string   `toml:"reset_time"`
+	WordCount      int      `toml:"word_count"`
+	EntriesPerPage int      `toml:"entries_per_page"`
+	LogLevel       string   `toml:"log_level"`
+	ChallengeSeed  string   `toml:"challenge_seed"`
+	QuotesAPIURL   string   `toml:"quotes_api_url"`
+	// HTTPAddress is where the read-only JSON API listens. It is off when
+	// empty.
+	HTTPAddress string `toml:"http_address"`
+	// AdminFingerprints are the SHA256 public key fingerprints
+	// ("SHA256:...") allowed to review flagged runs.
+	AdminFingerprints []string `toml:"admin_fingerprints"`
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() Config {
+	return Config{
+		ListenAddress:  "0.0.0.0:22", // Bind to all interfaces for production
+		DBPath:         "badger_db",
+		HostKeyPaths:   []string{".ssh/id_ed25519"},
+		Timezone:       "America/Los_Angeles",
+		ResetTime:      "00:00",
+		WordCount:      38,
+		EntriesPerPage: 10,
+		LogLevel:       "info",
+	}
+}
+
+// envVars maps each setting to the environment variable that overrides it.
+var envVars = map[string]string{
+	"listen-address":     "LISTEN_ADDRESS",
+	"db-path":            "DB_PATH",
+	"host-key-path":      "SSH_HOST_KEY_PATH",
+	"timezone":           "CHALLENGE_TIMEZONE",
+	"reset-time":         "CHALLENGE_RESET_TIME",
+	"word-count":         "WORD_COUNT",
+	"entries-per-page":   "ENTRIES_PER_PAGE",
+	"log-level":          "LOG_LEVEL",
+	"challenge-seed":     "CHALLENGE_SEED",
+	"quotes-api-url":     "QUOTES_API_URL",
+	"http-address":       "HTTP_ADDRESS",
+	"admin-fingerprints": "ADMIN_FINGERPRINTS",
+}
+
+// Load builds the configuration from args (without the program name), the
+// environment and the config file named by -config or CONFIG_FILE.
+func Load(args []string) (*Config, error) {
+	return LoadFlags(flag.NewFlagSet("monkeyy", flag.ContinueOnError), args)
+}
+
+// LoadFlags is Load for tools with flags of their own: the config flags are
+// added to fs, and fs parses args, so the tool's flags are set too once it
+// returns.
+func LoadFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a TOML config file")
+	overrides := map[string]*string{}
+	for name, env := range envVars {
+		overrides[name] = fs.String(name, "", fmt.Sprintf("overrides the config file (env %s)", env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *configPath != "" {
+		meta, err := toml.DecodeFile(*configPath, &cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", *configPath, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("unknown setting %q in config file %s", undecoded[0].String(), *configPath)
+		}
+	}
+
+	for name, env := range envVars {
+		if value := os.Getenv(env); value != "" {
+			if err := cfg.set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		if value, ok := overrides[f.Name]; ok && flagErr == nil {
+			if err := cfg.set(f.Name, *value); err != nil {
+				flagErr = fmt.Errorf("invalid -%s: %w", f.Name, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) set(name string, value string) error {
+	switch name {
+	case "listen-address":
+		c.ListenAddress = value
+	case "db-path":
+		c.DBPath = value
+	case "host-key-path":
+		c.HostKeyPaths = strings.Split(value, ",")
+	case "timezone":
+		c.Timezone = value
+	case "reset-time":
+		c.ResetTime = value
+	case "word-count":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		c.WordCount = n
+	case "entries-per-page":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		c.EntriesPerPage = n
+	case "log-level":
+		c.LogLevel = value
+	case "challenge-seed":
+		c.ChallengeSeed = value
+	case "quotes-api-url":
+		c.QuotesAPIURL = value
+	case "http-address":
+		c.HTTPAddress = value
+	case "admin-fingerprints":
+		c.AdminFingerprints = strings.Split(value, ",")
+	default:
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	return nil
+}
+
+// Validate checks every setting and reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := validateAddress("listen_address", c.ListenAddress); err != nil {
+		errs = append(errs, err)
+	}
+	if c.HTTPAddress != "" {
+		if err := validateAddress("http_address", c.HTTPAddress); err != nil {
+			errs = append(errs, err)
+		}
+		if c.HTTPAddress == c.ListenAddress {
+			errs = append(errs, fmt.Errorf("http_address %q is already the SSH listen_address", c.HTTPAddress))
+		}
+	}
+
+	if strings.TrimSpace(c.DBPath) == "" {
+		errs = append(errs, errors.New("db_path must not be empty"))
+	}
+
+	if len(c.HostKeyPaths) == 0 {
+		errs = append(errs, errors.New("at least one host key path is required"))
+	}
+	for _, path := range c.HostKeyPaths {
+		if strings.TrimSpace(path) == "" {
+			errs = append(errs, errors.New("host key paths must not be empty"))
+			break
+		}
+	}
+
+	if _, err := c.ChallengeClock(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.WordCount < 5 || c.WordCount > 500 {
+		errs = append(errs, fmt.Errorf("word_count must be between 5 and 500, got %d", c.WordCount))
+	}
+	if c.EntriesPerPage < 1 || c.EntriesPerPage > 100 {
+		errs = append(errs, fmt.Errorf("entries_per_page must be between 1 and 100, got %d", c.EntriesPerPage))
+	}
+
+	for _, fingerprint := range c.AdminFingerprints {
+		if !strings.HasPrefix(fingerprint, "SHA256:") {
+			errs = append(errs, fmt.Errorf("admin fingerprint %q must start with SHA256:", fingerprint))
+		}
+	}
+
+	if _, err := log.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("log_level %q must be one of debug, info, warn, error, fatal", c.LogLevel))
+	}
+
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(msgs, "\n  - "))
+	}
+	return nil
+}
+
+// validateAddress checks a host:port address to listen on. The host may be
+// an IP address, a hostname such as localhost, or empty for every
+// interface.
+func validateAddress(setting string, address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%s %q: %w", setting, address, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s %q: port must be between 1 and 65535", setting, address)
+	}
+	if strings.ContainsAny(host, " /") {
+		return fmt.Errorf("%s %q: %q is not a hostname or IP address", setting, address, host)
+	}
+	return nil
+}
+
+// ChallengeClock builds the challenge day clock from Timezone and ResetTime.
+func (c *Config) ChallengeClock() (data.ChallengeClock, error) {
+	hour, minute, err := data.ParseResetTime(c.ResetTime)
+	if err != nil {
+		return data.ChallengeClock{}, fmt.Errorf("reset_time: %w", err)
+	}
+	clock, err := data.NewChallengeClock(c.Timezone, hour, minute)
+	if err != nil {
+		return data.ChallengeClock{}, fmt.Errorf("timezone: %w", err)
+	}
+	return clock, nil
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every setting's environment variable for the test, so the
+// machine running it can't change the result.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range envVars {
+		t.Setenv(env, "")
+	}
+	t.Setenv("CONFIG_FILE", "")
+}
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `
+listen_address = "localhost:2222"
+word_count = 20
+entries_per_page = 15
+log_level = "warn"
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("WORD_COUNT", "25")
+	t.Setenv("LOG_LEVEL", "debug")
+
+	cfg, err := Load([]string{"-log-level", "error"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.ListenAddress != "localhost:2222" || cfg.EntriesPerPage != 15 {
+		t.Errorf("file settings not applied: %+v", cfg)
+	}
+	if cfg.WordCount != 25 {
+		t.Errorf("word_count = %d, want the environment's 25 over the file's 20", cfg.WordCount)
+	}
+	if cfg.LogLevel != "error" {
+		t.Errorf("log_level = %q, want the flag's error over the environment's debug", cfg.LogLevel)
+	}
+	if cfg.DBPath != Default().DBPath {
+		t.Errorf("db_path = %q, want the default", cfg.DBPath)
+	}
+
+	// -config beats CONFIG_FILE
+	other := writeConfig(t, `entries_per_page = 30`)
+	cfg, err = Load([]string{"-config", other})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.EntriesPerPage != 30 || cfg.ListenAddress != Default().ListenAddress {
+		t.Errorf("-config not used: %+v", cfg)
+	}
+}
+
+func TestLoadFlags(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `word_count = 20`)
+
+	fs := flag.NewFlagSet("tool", flag.ContinueOnError)
+	date := fs.String("date", "", "")
+	cfg, err := LoadFlags(fs, []string{"-config", path, "-date", "2026-05-10", "-log-level", "warn"})
+	if err != nil {
+		t.Fatalf("LoadFlags: %v", err)
+	}
+	if cfg.WordCount != 20 || cfg.LogLevel != "warn" {
+		t.Errorf("config flags not applied: %+v", cfg)
+	}
+	if *date != "2026-05-10" {
+		t.Errorf("-date = %q, want the tool's flag parsed too", *date)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{"unknown file setting", `colour = "blue"`, nil, nil, `unknown setting "colour"`},
+		{"bad env number", "", map[string]string{"WORD_COUNT": "many"}, nil, "invalid WORD_COUNT"},
+		{"bad flag number", "", nil, []string{"-entries-per-page", "ten"}, "invalid -entries-per-page"},
+		{"unknown flag", "", nil, []string{"-colour", "blue"}, "colour"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.file != "" {
+				t.Setenv("CONFIG_FILE", writeConfig(t, tt.file))
+			}
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+			_, err := Load(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		wantErr string
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"hostname", func(c *Config) { c.ListenAddress = "localhost:2222" }, ""},
+		{"every interface", func(c *Config) { c.ListenAddress = ":2222" }, ""},
+		{"ipv6", func(c *Config) { c.ListenAddress = "[::1]:2222" }, ""},
+		{"http api", func(c *Config) { c.HTTPAddress = "localhost:8080" }, ""},
+		{"no port", func(c *Config) { c.ListenAddress = "localhost" }, "listen_address"},
+		{"port out of range", func(c *Config) { c.ListenAddress = "localhost:70000" }, "port must be between 1 and 65535"},
+		{"bad host", func(c *Config) { c.ListenAddress = "local host:22" }, "not a hostname"},
+		{"http api on the ssh address", func(c *Config) { c.HTTPAddress = c.ListenAddress }, "already the SSH listen_address"},
+		{"empty db path", func(c *Config) { c.DBPath = " " }, "db_path"},
+		{"no host keys", func(c *Config) { c.HostKeyPaths = nil }, "host key path"},
+		{"unknown timezone", func(c *Config) { c.Timezone = "Mars/Olympus_Mons" }, "timezone"},
+		{"bad reset time", func(c *Config) { c.ResetTime = "25:00" }, "reset_time"},
+		{"word count", func(c *Config) { c.WordCount = 4 }, "word_count"},
+		{"entries per page", func(c *Config) { c.EntriesPerPage = 0 }, "entries_per_page"},
+		{"admin fingerprint", func(c *Config) { c.AdminFingerprints = []string{"MD5:aa"} }, "must start with SHA256:"},
+		{"log level", func(c *Config) { c.LogLevel = "loud" }, "log_level"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// every problem is reported at once
+	cfg := Default()
+	cfg.WordCount = 0
+	cfg.LogLevel = "loud"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "word_count") || !strings.Contains(err.Error(), "log_level") {
+		t.Errorf("Validate = %v, want both problems", err)
+	}
+}
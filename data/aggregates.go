@@ -0,0 +1,396 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	historyPrefix   = "history:"
+	aggregatePrefix = "agg:player:"
+	bestDayPrefix   = "agg:best:"
+	// aggregatesBuiltKey marks a database whose aggregates are up to date.
+	// It is versioned so aggregates are rebuilt when they gain fields or
+	// indexes.
+	aggregatesBuiltKey = "meta:aggregates:3"
+)
+
+// recentDays is how many days of results a player's aggregate keeps for the
+// windowed boards.
+const recentDays = 30
+
+// Aggregate leaderboards, computed across days.
+const (
+	BoardAllTime    = "all-time"
+	BoardWeekly     = "weekly"
+	BoardMonthly    = "monthly"
+	BoardMostPlayed = "most-played"
+	BoardStreaks    = "streaks"
+)
+
+// The weekly and monthly boards only rank players who played at least this
+// many days of the window.
+var (
+	WeeklyMinDays  = 3
+	MonthlyMinDays = 10
+)
+
+var ErrUnknownBoard = errors.New("unknown leaderboard")
+
+// DailyResult is a player's ranked result on one day.
+type DailyResult struct {
+	DateID   string  `json:"date_id"`
+	WPM      int     `json:"wpm"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+// PlayerAggregate is kept up to date as a player's daily results come in, so
+// the aggregate boards never have to rescan past days.
+type PlayerAggregate struct {
+	PlayerID   string        `json:"player_id"`
+	Username   string        `json:"username"`
+	DaysPlayed int           `json:"days_played"`
+	TotalWPM   int           `json:"total_wpm"`
+	BestWPM    int           `json:"best_wpm"`
+	BestDateID string        `json:"best_date_id"`
+	LastDateID string        `json:"last_date_id"`
+	Recent     []DailyResult `json:"recent"`
+	// StreakDays is the length of the run of consecutive days ending on
+	// LastDateID.
+	StreakDays    int `json:"streak_days"`
+	LongestStreak int `json:"longest_streak"`
+}
+
+// AggregateEntry is one row of an aggregate board. WPM is the best day's
+// WPM on the all-time, most-played and streak boards and the average over
+// the window on the weekly and monthly ones.
+type AggregateEntry struct {
+	UserID     string  `json:"user_id"`
+	Username   string  `json:"username"`
+	WPM        float64 `json:"wpm"`
+	DaysPlayed int     `json:"days_played"`
+	DateID     string  `json:"date_id,omitempty"`
+	Streak     Streak  `json:"streak"`
+}
+
+// historyKey indexes the days a player has a result on.
+func historyKey(playerID string, dateID string) string {
+	return historyPrefix + playerID + ":" + dateID
+}
+
+func aggregateKey(playerID string) string {
+	return aggregatePrefix + playerID
+}
+
+// bestDayKey indexes players by their best day's WPM, fastest first, so a
+// player can be placed among everyone without reading every aggregate.
+func bestDayKey(wpm int, playerID string) string {
+	wpm = min(max(wpm, 0), maxRankWPM)
+	return fmt.Sprintf("%s%06d:%s", bestDayPrefix, maxRankWPM-wpm, playerID)
+}
+
+// putAggregateTxn stores a player's aggregate and moves them in the best
+// day index. An aggregate without days played is removed from both.
+func putAggregateTxn(txn *badger.Txn, aggregate PlayerAggregate) error {
+	var previous PlayerAggregate
+	err := getTxnValue(txn, aggregateKey(aggregate.PlayerID), &previous)
+	if err == nil {
+		if err := txn.Delete([]byte(bestDayKey(previous.BestWPM, aggregate.PlayerID))); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+
+	if aggregate.DaysPlayed == 0 {
+		return txn.Delete([]byte(aggregateKey(aggregate.PlayerID)))
+	}
+	if err := txn.Set([]byte(bestDayKey(aggregate.BestWPM, aggregate.PlayerID)), nil); err != nil {
+		return err
+	}
+	return setTxnValue(txn, aggregateKey(aggregate.PlayerID), aggregate)
+}
+
+// countsOnLeaderboard reports whether a run with status is ranked.
+func countsOnLeaderboard(status string) bool {
+	return status != RunStatusRejected
+}
+
+// add folds one day's result into the aggregate. Days must be added oldest
+// first for the streaks to be counted.
+func (a *PlayerAggregate) add(dateID string, entry LeaderBoardEntry) {
+	a.Username = entry.Username
+	a.DaysPlayed++
+	a.TotalWPM += entry.WPM
+	if a.BestDateID == "" || entry.WPM > a.BestWPM {
+		a.BestWPM = entry.WPM
+		a.BestDateID = dateID
+	}
+	if dateID > a.LastDateID {
+		if next, err := ShiftDateID(a.LastDateID, 1); err == nil && next == dateID {
+			a.StreakDays++
+		} else {
+			a.StreakDays = 1
+		}
+		a.LongestStreak = max(a.LongestStreak, a.StreakDays)
+		a.LastDateID = dateID
+	}
+
+	a.Recent = append(a.Recent, DailyResult{DateID: dateID, WPM: entry.WPM, Accuracy: entry.Stats.Accuracy})
+	slices.SortFunc(a.Recent, func(x, y DailyResult) int { return strings.Compare(x.DateID, y.DateID) })
+	if cutoff, err := ShiftDateID(a.LastDateID, -(recentDays - 1)); err == nil {
+		a.Recent = slices.DeleteFunc(a.Recent, func(r DailyResult) bool { return r.DateID < cutoff })
+	}
+}
+
+// addToAggregateTxn counts a new ranked result towards its player's
+// aggregate. The result must already be stored with its history key. A day
+// older than the player's latest, such as yesterday's run submitted after
+// today's, can't be folded in without breaking the streak, so the aggregate
+// is rebuilt instead.
+func addToAggregateTxn(txn *badger.Txn, dateID string, entry LeaderBoardEntry) error {
+	var aggregate PlayerAggregate
+	err := getTxnValue(txn, aggregateKey(entry.UserID), &aggregate)
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+	if err == nil && dateID <= aggregate.LastDateID {
+		return rebuildAggregateTxn(txn, entry.UserID)
+	}
+	aggregate.PlayerID = entry.UserID
+	aggregate.add(dateID, entry)
+	return putAggregateTxn(txn, aggregate)
+}
+
+// rebuildAggregateTxn recomputes a player's aggregate from their history,
+// for when a past result changes.
+func rebuildAggregateTxn(txn *badger.Txn, playerID string) error {
+	aggregate := PlayerAggregate{PlayerID: playerID}
+	prefix := []byte(historyPrefix + playerID + ":")
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	for it.Rewind(); it.Valid(); it.Next() {
+		dateID := string(it.Item().Key()[len(prefix):])
+		var entry LeaderBoardEntry
+		if err := getTxnValue(txn, scoreKey(dateID, playerID), &entry); err != nil {
+			continue
+		}
+		if countsOnLeaderboard(entry.Status) {
+			aggregate.add(dateID, entry)
+		}
+	}
+	it.Close()
+
+	return putAggregateTxn(txn, aggregate)
+}
+
+// backfillAggregates builds the history index and aggregates for results
+// stored before they existed. It runs once per database.
+func backfillAggregates() error {
+	err := db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(aggregatesBuiltKey))
+		return err
+	})
+	if err == nil {
+		return nil
+	}
+
+	players := map[string][]string{}
+	err = db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(scorePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			dateID, playerID, ok := strings.Cut(strings.TrimPrefix(string(it.Item().Key()), scorePrefix), ":")
+			if ok {
+				players[playerID] = append(players[playerID], dateID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for playerID, dates := range players {
+		err := db.Update(func(txn *badger.Txn) error {
+			for _, dateID := range dates {
+				if err := txn.Set([]byte(historyKey(playerID, dateID)), nil); err != nil {
+					return err
+				}
+			}
+			return rebuildAggregateTxn(txn, playerID)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to backfill aggregates for %s: %w", playerID, err)
+		}
+	}
+	if len(players) > 0 {
+		log.Printf("Built aggregates for %d players", len(players))
+	}
+	return db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(aggregatesBuiltKey), nil)
+	})
+}
+
+// GetPlayerAggregate returns a player's aggregate, or an empty one if they
+// have no ranked results.
+func GetPlayerAggregate(playerID string) (*PlayerAggregate, error) {
+	aggregate := PlayerAggregate{PlayerID: playerID}
+	err := db.View(func(txn *badger.Txn) error {
+		return getTxnValue(txn, aggregateKey(playerID), &aggregate)
+	})
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, fmt.Errorf("failed to read aggregate for %s: %w", playerID, err)
+	}
+	return &aggregate, nil
+}
+
+// GetAggregateBoard ranks players across days: BoardAllTime by their best
+// single day, BoardWeekly and BoardMonthly by their average over the last 7
+// or 30 days (for players who played enough of them), BoardMostPlayed by
+// days played and BoardStreaks by current streak, for players who have one.
+// A limit of zero returns every entry.
+func GetAggregateBoard(board string, limit int) ([]AggregateEntry, error) {
+	window, minDays := 0, 0
+	switch board {
+	case BoardAllTime, BoardMostPlayed, BoardStreaks:
+	case BoardWeekly:
+		window, minDays = 7, WeeklyMinDays
+	case BoardMonthly:
+		window, minDays = 30, MonthlyMinDays
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownBoard, board)
+	}
+
+	today := getCurrentDateID()
+	cutoff := ""
+	if window > 0 {
+		var err error
+		if cutoff, err = ShiftDateID(today, -(window - 1)); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := []AggregateEntry{}
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(aggregatePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var aggregate PlayerAggregate
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &aggregate)
+			}); err != nil {
+				return err
+			}
+
+			entry := AggregateEntry{
+				UserID:   aggregate.PlayerID,
+				Username: aggregate.Username,
+				Streak:   aggregate.streak(today),
+			}
+			if board == BoardStreaks && entry.Streak.Current == 0 {
+				continue
+			}
+			if window == 0 {
+				entry.WPM = float64(aggregate.BestWPM)
+				entry.DaysPlayed = aggregate.DaysPlayed
+				entry.DateID = aggregate.BestDateID
+			} else {
+				total := 0
+				for _, result := range aggregate.Recent {
+					if result.DateID >= cutoff && result.DateID <= today {
+						total += result.WPM
+						entry.DaysPlayed++
+					}
+				}
+				if entry.DaysPlayed < minDays || entry.DaysPlayed == 0 {
+					continue
+				}
+				entry.WPM = float64(total) / float64(entry.DaysPlayed)
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s leaderboard: %w", board, err)
+	}
+
+	slices.SortStableFunc(entries, func(a, b AggregateEntry) int {
+		if board == BoardStreaks {
+			if a.Streak.Current != b.Streak.Current {
+				return b.Streak.Current - a.Streak.Current
+			}
+			if a.Streak.Longest != b.Streak.Longest {
+				return b.Streak.Longest - a.Streak.Longest
+			}
+		}
+		if board == BoardMostPlayed && a.DaysPlayed != b.DaysPlayed {
+			return b.DaysPlayed - a.DaysPlayed
+		}
+		if a.WPM != b.WPM {
+			if a.WPM > b.WPM {
+				return -1
+			}
+			return 1
+		}
+		if a.DaysPlayed != b.DaysPlayed {
+			return b.DaysPlayed - a.DaysPlayed
+		}
+		return strings.Compare(a.Username, b.Username)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// AggregatePage is one page of an aggregate board, for screens that show a
+// board a page at a time. Own is playerID's entry and OwnRow its row on the
+// whole board, or nil and -1 if they aren't on it.
+type AggregatePage struct {
+	Board   string
+	Entries []AggregateEntry
+	Total   int
+	Own     *AggregateEntry
+	OwnRow  int
+}
+
+// GetAggregateBoardPage returns limit entries of an aggregate board from
+// offset, along with playerID's own entry.
+func GetAggregateBoardPage(board string, playerID string, offset int, limit int) (*AggregatePage, error) {
+	entries, err := GetAggregateBoard(board, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	start := min(max(offset, 0), len(entries))
+	end := min(start+max(limit, 0), len(entries))
+	page := &AggregatePage{
+		Board:   board,
+		Entries: slices.Clone(entries[start:end]),
+		Total:   len(entries),
+		OwnRow:  slices.IndexFunc(entries, func(entry AggregateEntry) bool { return entry.UserID == playerID }),
+	}
+	if page.OwnRow >= 0 {
+		own := entries[page.OwnRow]
+		page.Own = &own
+	}
+	return page, nil
+}
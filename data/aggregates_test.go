@@ -0,0 +1,180 @@
+package data
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func daysAgo(t *testing.T, days int) string {
+	t.Helper()
+	dateID, err := ShiftDateID(TodayID(), -days)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dateID
+}
+
+func boardUsernames(t *testing.T, board string) []string {
+	t.Helper()
+	entries, err := GetAggregateBoard(board, 0)
+	if err != nil {
+		t.Fatalf("GetAggregateBoard(%s): %v", board, err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Username)
+	}
+	return names
+}
+
+func seedAggregateFixture(t *testing.T) {
+	t.Helper()
+	// alice played the last three days, bob only today, carol ten days
+	// ending a week ago and once long ago. Days are seeded oldest first,
+	// the order results come in.
+	for i, wpm := range []int{80, 70, 60} {
+		seedDay(t, daysAgo(t, 2-i), "text", LeaderBoardEntry{UserID: "a", Username: "alice", WPM: wpm})
+	}
+	seedDay(t, daysAgo(t, 0), "text", LeaderBoardEntry{UserID: "b", Username: "bob", WPM: 100})
+	seedDay(t, daysAgo(t, 40), "text", LeaderBoardEntry{UserID: "c", Username: "carol", WPM: 120})
+	for i := 17; i >= 8; i-- {
+		seedDay(t, daysAgo(t, i), "text", LeaderBoardEntry{UserID: "c", Username: "carol", WPM: 50})
+	}
+}
+
+func checkAggregateBoards(t *testing.T) {
+	t.Helper()
+	tests := []struct {
+		board string
+		want  []string
+	}{
+		{BoardWeekly, []string{"alice"}},
+		{BoardMonthly, []string{"carol"}},
+		{BoardAllTime, []string{"carol", "bob", "alice"}},
+		{BoardMostPlayed, []string{"carol", "alice", "bob"}},
+	}
+	for _, tt := range tests {
+		if got := boardUsernames(t, tt.board); !slices.Equal(got, tt.want) {
+			t.Errorf("%s board = %v, want %v", tt.board, got, tt.want)
+		}
+	}
+
+	weekly, _ := GetAggregateBoard(BoardWeekly, 0)
+	if len(weekly) == 1 && (weekly[0].WPM != 70 || weekly[0].DaysPlayed != 3) {
+		t.Errorf("weekly alice = %+v, want 70 WPM over 3 days", weekly[0])
+	}
+	monthly, _ := GetAggregateBoard(BoardMonthly, 0)
+	if len(monthly) == 1 && (monthly[0].WPM != 50 || monthly[0].DaysPlayed != 10) {
+		t.Errorf("monthly carol = %+v, want 50 WPM over 10 days", monthly[0])
+	}
+}
+
+func TestAggregateBoards(t *testing.T) {
+	openTestStore(t)
+	seedAggregateFixture(t)
+	checkAggregateBoards(t)
+
+	if _, err := GetAggregateBoard("yearly", 0); err == nil {
+		t.Error("unknown board did not fail")
+	}
+
+	if err := ReviewRun(daysAgo(t, 0), "b", false); err != nil {
+		t.Fatalf("ReviewRun: %v", err)
+	}
+	if got := boardUsernames(t, BoardAllTime); !slices.Equal(got, []string{"carol", "alice"}) {
+		t.Errorf("all-time board after rejecting bob = %v", got)
+	}
+}
+
+func TestAggregateOutOfOrder(t *testing.T) {
+	openTestStore(t)
+	// yesterday's run comes in after today's, as a run that crossed the
+	// reset can
+	for _, day := range []struct {
+		ago int
+		wpm int
+	}{{0, 70}, {2, 80}, {1, 80}} {
+		seedDay(t, daysAgo(t, day.ago), "text", LeaderBoardEntry{UserID: "a", Username: "alice", WPM: day.wpm})
+	}
+
+	incremental, err := GetPlayerAggregate("a")
+	if err != nil {
+		t.Fatalf("GetPlayerAggregate: %v", err)
+	}
+	if err := db.Update(func(txn *badger.Txn) error { return rebuildAggregateTxn(txn, "a") }); err != nil {
+		t.Fatalf("rebuildAggregateTxn: %v", err)
+	}
+	rebuilt, err := GetPlayerAggregate("a")
+	if err != nil {
+		t.Fatalf("GetPlayerAggregate: %v", err)
+	}
+	if !reflect.DeepEqual(incremental, rebuilt) {
+		t.Errorf("incremental aggregate %+v, rebuilt %+v", incremental, rebuilt)
+	}
+	if incremental.StreakDays != 3 || incremental.BestDateID != daysAgo(t, 2) {
+		t.Errorf("aggregate = %+v, want a 3 day streak and the best day 2 days ago", incremental)
+	}
+}
+
+func TestGetAggregateBoardPage(t *testing.T) {
+	openTestStore(t)
+	seedAggregateFixture(t)
+
+	page, err := GetAggregateBoardPage(BoardAllTime, "a", 1, 1)
+	if err != nil {
+		t.Fatalf("GetAggregateBoardPage: %v", err)
+	}
+	if page.Total != 3 || len(page.Entries) != 1 || page.Entries[0].Username != "bob" {
+		t.Errorf("page = %+v, want bob alone of 3", page)
+	}
+	if page.Own == nil || page.Own.Username != "alice" || page.OwnRow != 2 {
+		t.Errorf("own entry = %+v at row %d, want alice at row 2", page.Own, page.OwnRow)
+	}
+
+	page, err = GetAggregateBoardPage(BoardAllTime, "nobody", 5, 10)
+	if err != nil {
+		t.Fatalf("GetAggregateBoardPage: %v", err)
+	}
+	if len(page.Entries) != 0 || page.Own != nil || page.OwnRow != -1 {
+		t.Errorf("page past the end = %+v, want it empty", page)
+	}
+}
+
+func TestBackfillAggregates(t *testing.T) {
+	openTestStore(t)
+	seedAggregateFixture(t)
+
+	// drop everything derived, as if the results predate aggregation
+	err := db.Update(func(txn *badger.Txn) error {
+		for _, prefix := range []string{historyPrefix, aggregatePrefix, bestDayPrefix, aggregatesBuiltKey} {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = []byte(prefix)
+			it := txn.NewIterator(opts)
+			var keys [][]byte
+			for it.Rewind(); it.Valid(); it.Next() {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+			it.Close()
+			for _, key := range keys {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := boardUsernames(t, BoardAllTime); len(got) != 0 {
+		t.Fatalf("aggregates left behind: %v", got)
+	}
+
+	if err := backfillAggregates(); err != nil {
+		t.Fatalf("backfillAggregates: %v", err)
+	}
+	checkAggregateBoards(t)
+}
@@ -0,0 +1,253 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"monkeyy/typing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const flagPrefix = "flag:"
+
+// Run statuses stored on leaderboard entries.
+const (
+	RunStatusOK       = ""
+	RunStatusFlagged  = "flagged"
+	RunStatusRejected = "rejected"
+	RunStatusApproved = "approved"
+)
+
+// Reason codes explaining why a run was flagged or rejected.
+const (
+	ReasonTimelineMismatch = "timeline_mismatch"
+	ReasonPaste            = "paste"
+	ReasonBurst            = "burst"
+	ReasonWPMCeiling       = "wpm_ceiling"
+	ReasonFastIntervals    = "fast_intervals"
+	ReasonTooConsistent    = "too_consistent"
+	ReasonStatsMismatch    = "stats_mismatch"
+	ReasonAdminRejected    = "admin_rejected"
+)
+
+var ErrRunRejected = errors.New("run rejected")
+
+// AntiCheatRules are the thresholds runs are validated against.
+type AntiCheatRules struct {
+	// Runs faster than this are rejected outright.
+	MaxWPM float64
+	// Gaps between keys shorter than this count as implausibly fast.
+	MinIntervalMillis int64
+	// Runs where more than this share of gaps are too fast are flagged.
+	MaxFastIntervalRatio float64
+	// Runs with this many too-fast keys in a row are flagged. They aren't
+	// rejected: SSH clients on slow links deliver keys in batches that
+	// arrive 0ms apart.
+	BurstLength int
+	// Runs whose key gaps vary less than this (as a coefficient of
+	// variation) are flagged as scripted, once they have MinKeysForConsistency keys.
+	MinIntervalVariation  float64
+	MinKeysForConsistency int
+}
+
+var DefaultAntiCheatRules = AntiCheatRules{
+	MaxWPM:                250,
+	MinIntervalMillis:     15,
+	MaxFastIntervalRatio:  0.3,
+	BurstLength:           8,
+	MinIntervalVariation:  0.1,
+	MinKeysForConsistency: 30,
+}
+
+var antiCheatRules = DefaultAntiCheatRules
+
+// SetAntiCheatRules replaces the rules used to validate submitted runs.
+func SetAntiCheatRules(rules AntiCheatRules) {
+	antiCheatRules = rules
+}
+
+// Verdict is the outcome of validating a run.
+type Verdict struct {
+	Status string
+	Reason string
+	Detail string
+}
+
+// FlaggedRun is a flagged or rejected run awaiting an admin's attention.
+type FlaggedRun struct {
+	DateID string           `json:"date_id"`
+	Entry  LeaderBoardEntry `json:"entry"`
+}
+
+func flagKey(dateID string, playerID string) string {
+	return flagPrefix + dateID + ":" + playerID
+}
+
+// ValidateRun checks a submitted run against its keystroke timeline. The
+// stats returned are recomputed from the timeline and replace the ones the
+// client claimed.
+func ValidateRun(text string, keystrokes []Keystroke, claimed RunStats) (RunStats, Verdict) {
+	rules := antiCheatRules
+	session := typing.Replay(text, keystrokes)
+	stats := session.Stats()
+
+	if !session.Finished() {
+		return stats, Verdict{RunStatusRejected, ReasonTimelineMismatch, "keystrokes do not reproduce the text"}
+	}
+
+	var intervals []float64
+	var burst *Verdict
+	fastRun, fastIntervals := 0, 0
+	for i, keystroke := range keystrokes {
+		if keystroke.Key != BackspaceKey && !typing.IsTypeable(keystroke.Key) {
+			return stats, Verdict{RunStatusRejected, ReasonPaste, fmt.Sprintf("key %d inserted %d characters", i, len(typing.Graphemes(keystroke.Key)))}
+		}
+		if i == 0 {
+			continue
+		}
+
+		interval := keystroke.OffsetMillis - keystrokes[i-1].OffsetMillis
+		if interval < 0 {
+			return stats, Verdict{RunStatusRejected, ReasonTimelineMismatch, "keystroke offsets go backwards"}
+		}
+		intervals = append(intervals, float64(interval))
+
+		if interval < rules.MinIntervalMillis {
+			fastIntervals++
+			fastRun++
+			if fastRun >= rules.BurstLength && burst == nil {
+				burst = &Verdict{RunStatusFlagged, ReasonBurst, fmt.Sprintf("%d keys in a row under %dms apart", fastRun, rules.MinIntervalMillis)}
+			}
+		} else {
+			fastRun = 0
+		}
+	}
+
+	if stats.NetWPM > rules.MaxWPM {
+		return stats, Verdict{RunStatusRejected, ReasonWPMCeiling, fmt.Sprintf("%.0f WPM is above the %.0f WPM ceiling", stats.NetWPM, rules.MaxWPM)}
+	}
+
+	if burst != nil {
+		return stats, *burst
+	}
+
+	if len(intervals) > 0 {
+		ratio := float64(fastIntervals) / float64(len(intervals))
+		if ratio > rules.MaxFastIntervalRatio {
+			return stats, Verdict{RunStatusFlagged, ReasonFastIntervals, fmt.Sprintf("%.0f%% of keys under %dms apart", ratio*100, rules.MinIntervalMillis)}
+		}
+	}
+
+	if len(keystrokes) >= rules.MinKeysForConsistency {
+		mean, stddev := meanAndStdDev(intervals)
+		if mean > 0 && stddev/mean < rules.MinIntervalVariation {
+			return stats, Verdict{RunStatusFlagged, ReasonTooConsistent, fmt.Sprintf("key gaps vary by only %.1f%%", 100*stddev/mean)}
+		}
+	}
+
+	if claimed.NetWPM > stats.NetWPM*1.1+5 {
+		return stats, Verdict{RunStatusFlagged, ReasonStatsMismatch, fmt.Sprintf("client claimed %.0f WPM, timeline shows %.0f", claimed.NetWPM, stats.NetWPM)}
+	}
+
+	return stats, Verdict{Status: RunStatusOK}
+}
+
+func meanAndStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// GetFlaggedRuns returns flagged and rejected runs that haven't been
+// reviewed, most recent day first.
+func GetFlaggedRuns(limit int) ([]FlaggedRun, error) {
+	runs := []FlaggedRun{}
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Reverse = true
+		opts.Prefix = []byte(flagPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(append([]byte(flagPrefix), 0xff)); it.Valid(); it.Next() {
+			var run FlaggedRun
+			if err := it.Item().Value(func(val []byte) error {
+				run.DateID = string(val)
+				return nil
+			}); err != nil {
+				return err
+			}
+			playerID := string(it.Item().Key()[len(flagPrefix)+len(run.DateID)+1:])
+			if err := getTxnValue(txn, scoreKey(run.DateID, playerID), &run.Entry); err != nil {
+				continue
+			}
+			runs = append(runs, run)
+			if limit > 0 && len(runs) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flagged runs: %w", err)
+	}
+	return runs, nil
+}
+
+// ReviewRun records an admin's decision on a flagged run. Approved runs are
+// ranked normally and can set the player's daily personal best; rejected
+// runs are removed from the leaderboard.
+func ReviewRun(dateID string, playerID string, approve bool) error {
+	return updateWithRetry(context.Background(), func(txn *badger.Txn) error {
+		var entry LeaderBoardEntry
+		if err := getTxnValue(txn, scoreKey(dateID, playerID), &entry); err != nil {
+			return fmt.Errorf("no run for %s on %s: %w", playerID, dateID, err)
+		}
+
+		rank := []byte(rankKey(dateID, entry.WPM, entry.SubmittedAt.UnixNano(), playerID))
+		if approve {
+			entry.Status = RunStatusApproved
+			if err := txn.Set(rank, nil); err != nil {
+				return err
+			}
+			var replay Replay
+			if err := getTxnValue(txn, replayKey(dateID, playerID), &replay); err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+			if _, _, err := updateBestTxn(txn, playerID, DailyMode, replay.Text, entry.Stats, replay.Keystrokes); err != nil {
+				return err
+			}
+		} else {
+			entry.Status = RunStatusRejected
+			if entry.FlagReason == "" {
+				entry.FlagReason = ReasonAdminRejected
+			}
+			if err := txn.Delete(rank); err != nil {
+				return err
+			}
+		}
+
+		if err := txn.Delete([]byte(flagKey(dateID, playerID))); err != nil {
+			return err
+		}
+		if err := setTxnValue(txn, scoreKey(dateID, playerID), entry); err != nil {
+			return err
+		}
+		return rebuildAggregateTxn(txn, playerID)
+	})
+}
@@ -0,0 +1,167 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"monkeyy/typing"
+	"testing"
+	"time"
+)
+
+func TestValidateRunUnicode(t *testing.T) {
+	const text = "naïve café owners serve crème brûlée to 🧑‍🍳 chefs"
+	_, verdict := ValidateRun(text, humanTimeline(text, 1), RunStats{})
+	if verdict.Status != RunStatusOK {
+		t.Fatalf("verdict = %+v, want ok", verdict)
+	}
+
+	keystrokes := humanTimeline(text, 2)
+	pasted := Keystroke{OffsetMillis: keystrokes[3].OffsetMillis, Key: "ve c"}
+	keystrokes = append(append(keystrokes[:3:3], pasted), keystrokes[7:]...)
+	if _, verdict := ValidateRun(text, keystrokes, RunStats{}); verdict.Reason != ReasonPaste {
+		t.Errorf("multi-character key: reason = %q, want %q", verdict.Reason, ReasonPaste)
+	}
+}
+
+// timeline types text key by key, waiting gap(i) before key i.
+func timeline(text string, gap func(i int) int64) []Keystroke {
+	keystrokes := []Keystroke{}
+	offset := int64(0)
+	for i, char := range typing.Graphemes(text) {
+		if i > 0 {
+			offset += gap(i)
+		}
+		keystrokes = append(keystrokes, Keystroke{OffsetMillis: offset, Key: char})
+	}
+	return keystrokes
+}
+
+func TestValidateRunRules(t *testing.T) {
+	const text = "the quick brown fox jumps over the lazy dog"
+	// irregular gaps of 60 to 200ms, about 90 WPM
+	human := func(i int) int64 { return 60 + int64(i*37%140) }
+
+	backwards := humanTimeline(text, 3)
+	backwards[5].OffsetMillis = backwards[4].OffsetMillis - 1
+
+	tests := []struct {
+		name       string
+		keystrokes []Keystroke
+		claimed    RunStats
+		status     string
+		reason     string
+	}{
+		{"human run", timeline(text, human), RunStats{}, RunStatusOK, ""},
+		{"above the WPM ceiling", timeline(text, func(int) int64 { return 20 }), RunStats{}, RunStatusRejected, ReasonWPMCeiling},
+		{"half the keys too fast", timeline(text, func(i int) int64 { return []int64{5, 200}[i%2] }), RunStats{}, RunStatusFlagged, ReasonFastIntervals},
+		{"batched keys", timeline(text, func(i int) int64 {
+			if i >= 10 && i < 20 {
+				return 0
+			}
+			return human(i)
+		}), RunStats{}, RunStatusFlagged, ReasonBurst},
+		{"burst above the ceiling", timeline(text, func(i int) int64 { return []int64{0, 30}[i%10/9] }), RunStats{}, RunStatusRejected, ReasonWPMCeiling},
+		{"evenly spaced keys", timeline(text, func(int) int64 { return 150 }), RunStats{}, RunStatusFlagged, ReasonTooConsistent},
+		{"claimed stats too high", timeline(text, human), RunStats{NetWPM: 200}, RunStatusFlagged, ReasonStatsMismatch},
+		{"timeline of another text", timeline("the quick brown fox jumps over the lazy cat", human), RunStats{}, RunStatusRejected, ReasonTimelineMismatch},
+		{"unfinished text", timeline(text, human)[:20], RunStats{}, RunStatusRejected, ReasonTimelineMismatch},
+		{"offsets going backwards", backwards, RunStats{}, RunStatusRejected, ReasonTimelineMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, verdict := ValidateRun(text, tt.keystrokes, tt.claimed)
+			if verdict.Status != tt.status || verdict.Reason != tt.reason {
+				t.Errorf("verdict = %+v (%.0f WPM), want %q %q", verdict, stats.NetWPM, tt.status, tt.reason)
+			}
+		})
+	}
+}
+
+func TestReviewRun(t *testing.T) {
+	openTestStore(t)
+	const text = "the quick brown fox jumps over the lazy dog"
+	if err := InsertSentence(text); err != nil {
+		t.Fatalf("InsertSentence: %v", err)
+	}
+	today := getCurrentDateID()
+	submit := func(playerID string, keystrokes []Keystroke, claimed RunStats) {
+		t.Helper()
+		err := SubmitSentence(context.Background(), today, time.Now(), playerID, playerID, claimed, keystrokes)
+		if err != nil && !errors.Is(err, ErrRunRejected) {
+			t.Fatalf("SubmitSentence(%s): %v", playerID, err)
+		}
+	}
+	submit("a", humanTimeline(text, 1), RunStats{NetWPM: 300})
+	submit("b", humanTimeline(text, 2), RunStats{NetWPM: 300})
+	submit("c", timeline(text, func(int) int64 { return 20 }), RunStats{})
+	submit("d", humanTimeline(text, 4), RunStats{})
+
+	runs, err := GetFlaggedRuns(0)
+	if err != nil {
+		t.Fatalf("GetFlaggedRuns: %v", err)
+	}
+	if len(runs) != 3 || runs[0].DateID != today {
+		t.Fatalf("flagged runs = %+v, want a, b and c", runs)
+	}
+	if runs, _ := GetFlaggedRuns(1); len(runs) != 1 {
+		t.Errorf("GetFlaggedRuns(1) returned %d runs", len(runs))
+	}
+
+	for _, review := range []struct {
+		playerID string
+		approve  bool
+	}{{"a", true}, {"b", false}, {"c", true}} {
+		if err := ReviewRun(today, review.playerID, review.approve); err != nil {
+			t.Fatalf("ReviewRun(%s): %v", review.playerID, err)
+		}
+	}
+	if err := ReviewRun(today, "nobody", true); err == nil {
+		t.Error("reviewing a run that doesn't exist should fail")
+	}
+
+	tests := []struct {
+		playerID   string
+		ranked     bool
+		daysPlayed int
+	}{
+		{"a", true, 1},
+		// rejecting a flagged run takes it off the board and out of the
+		// aggregates
+		{"b", false, 0},
+		// approving a rejected run puts it back on both
+		{"c", true, 1},
+		{"d", true, 1},
+	}
+	for _, tt := range tests {
+		rank, _, err := GetPlayerRank(today, tt.playerID)
+		if err != nil {
+			t.Fatalf("GetPlayerRank: %v", err)
+		}
+		if (rank > 0) != tt.ranked {
+			t.Errorf("%s: rank %d, want ranked %v", tt.playerID, rank, tt.ranked)
+		}
+		aggregate, err := GetPlayerAggregate(tt.playerID)
+		if err != nil {
+			t.Fatalf("GetPlayerAggregate: %v", err)
+		}
+		if aggregate.DaysPlayed != tt.daysPlayed {
+			t.Errorf("%s: %d days played, want %d", tt.playerID, aggregate.DaysPlayed, tt.daysPlayed)
+		}
+	}
+
+	if runs, _ := GetFlaggedRuns(0); len(runs) != 0 {
+		t.Errorf("reviewed runs still flagged: %+v", runs)
+	}
+
+	// only the runs that were cleared set a daily best
+	for playerID, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		bests, err := GetPersonalBests(playerID)
+		if err != nil {
+			t.Fatalf("GetPersonalBests: %v", err)
+		}
+		best, ok := bests[DailyMode]
+		if ok != want || (ok && len(best.Keystrokes) == 0) {
+			t.Errorf("%s: daily best %+v, want one with keystrokes %v", playerID, best, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChallengeClock decides which challenge day a moment belongs to. A new day
+// starts at the reset time in Location, so a day can last 23 or 25 hours
+// across daylight saving transitions.
+type ChallengeClock struct {
+	Location    *time.Location
+	ResetHour   int
+	ResetMinute int
+}
+
+var challengeClock = defaultChallengeClock()
+
+func defaultChallengeClock() ChallengeClock {
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		location = time.UTC
+	}
+	return ChallengeClock{Location: location}
+}
+
+// NewChallengeClock builds a clock for the named IANA timezone with the reset
+// at hour:minute local time.
+func NewChallengeClock(timezone string, hour int, minute int) (ChallengeClock, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return ChallengeClock{}, fmt.Errorf("unknown timezone %q: %w", timezone, err)
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return ChallengeClock{}, fmt.Errorf("invalid reset time %02d:%02d", hour, minute)
+	}
+	return ChallengeClock{Location: location, ResetHour: hour, ResetMinute: minute}, nil
+}
+
+// ParseResetTime parses a reset time written as HH:MM.
+func ParseResetTime(value string) (int, int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid reset time %q, expected HH:MM", value)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// SetChallengeClock replaces the clock used for date IDs. It should be called
+// once at startup, before the store is opened.
+func SetChallengeClock(clock ChallengeClock) {
+	challengeClock = clock
+}
+
+// GetChallengeClock returns the clock used for date IDs.
+func GetChallengeClock() ChallengeClock {
+	return challengeClock
+}
+
+// resetOn returns the reset instant for a calendar day. When the reset time
+// falls in a daylight saving gap, the day starts when the clocks jump.
+func (c ChallengeClock) resetOn(year int, month time.Month, day int) time.Time {
+	reset := time.Date(year, month, day, c.ResetHour, c.ResetMinute, 0, 0, c.Location)
+	if reset.Hour() == c.ResetHour && reset.Minute() == c.ResetMinute {
+		return reset
+	}
+	start, end := reset.ZoneBounds()
+	if reset.Hour()*60+reset.Minute() < c.ResetHour*60+c.ResetMinute {
+		return end
+	}
+	return start
+}
+
+// DateID returns the ID of the challenge day t falls in.
+func (c ChallengeClock) DateID(t time.Time) string {
+	local := t.In(c.Location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.Location)
+	if t.Before(c.resetOn(local.Year(), local.Month(), local.Day())) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day.Format(dateIDLayout)
+}
+
+// NextReset returns the first day boundary after t.
+func (c ChallengeClock) NextReset(t time.Time) time.Time {
+	local := t.In(c.Location)
+	reset := c.resetOn(local.Year(), local.Month(), local.Day())
+	if !reset.After(t) {
+		reset = c.resetOn(local.Year(), local.Month(), local.Day()+1)
+	}
+	return reset
+}
+
+// CronSpec returns a cron schedule firing at the reset time. It is meant to
+// be used with the clock's Location.
+func (c ChallengeClock) CronSpec() string {
+	return fmt.Sprintf("%d %d * * *", c.ResetMinute, c.ResetHour)
+}
+
+// dateIDLayout is the format of challenge date IDs.
+const dateIDLayout = "2006-01-02"
+
+var ErrInvalidDate = errors.New("invalid date, expected YYYY-MM-DD")
+
+// ParseDateID checks a date ID and returns the calendar day it names, at
+// midnight UTC.
+func ParseDateID(dateID string) (time.Time, error) {
+	day, err := time.Parse(dateIDLayout, dateID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidDate, dateID)
+	}
+	return day, nil
+}
+
+// ShiftDateID returns the date ID days after dateID (before it if days is
+// negative).
+func ShiftDateID(dateID string, days int) (string, error) {
+	day, err := ParseDateID(dateID)
+	if err != nil {
+		return "", err
+	}
+	return day.AddDate(0, 0, days).Format(dateIDLayout), nil
+}
+
+// TodayID returns the ID of the current challenge day.
+func TodayID() string {
+	return getCurrentDateID()
+}
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func mustClock(t *testing.T, timezone string, hour int, minute int) ChallengeClock {
+	t.Helper()
+	clock, err := NewChallengeClock(timezone, hour, minute)
+	if err != nil {
+		t.Fatalf("NewChallengeClock: %v", err)
+	}
+	return clock
+}
+
+func TestChallengeClockDateID(t *testing.T) {
+	clock := mustClock(t, "America/Los_Angeles", 6, 30)
+	loc := clock.Location
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"before reset belongs to previous day", time.Date(2026, 5, 10, 6, 29, 59, 0, loc), "2026-05-09"},
+		{"at reset starts new day", time.Date(2026, 5, 10, 6, 30, 0, 0, loc), "2026-05-10"},
+		{"late evening", time.Date(2026, 5, 10, 23, 59, 0, 0, loc), "2026-05-10"},
+		{"utc input is converted", time.Date(2026, 5, 10, 13, 30, 0, 0, time.UTC), "2026-05-10"},
+		{"new year before reset", time.Date(2026, 1, 1, 3, 0, 0, 0, loc), "2025-12-31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clock.DateID(tt.at); got != tt.want {
+				t.Errorf("DateID(%v) = %s, want %s", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChallengeClockDSTDayLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		hour     int
+		dayStart time.Time
+		want     time.Duration
+	}{
+		{"spring forward midnight reset", "America/Los_Angeles", 0, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), 23 * time.Hour},
+		{"fall back midnight reset", "America/Los_Angeles", 0, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), 25 * time.Hour},
+		{"ordinary day", "America/Los_Angeles", 0, time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{"spring forward morning reset", "Europe/Berlin", 5, time.Date(2026, 3, 28, 0, 0, 0, 0, time.UTC), 23 * time.Hour},
+		{"fall back morning reset", "Europe/Berlin", 5, time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC), 25 * time.Hour},
+		{"utc never shifts", "UTC", 0, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := mustClock(t, tt.timezone, tt.hour, 0)
+			start := time.Date(tt.dayStart.Year(), tt.dayStart.Month(), tt.dayStart.Day(), tt.hour, 0, 0, 0, clock.Location)
+
+			end := clock.NextReset(start)
+			if got := end.Sub(start); got != tt.want {
+				t.Errorf("day starting %v lasts %v, want %v", start, got, tt.want)
+			}
+			if got, want := clock.DateID(start), start.Format("2006-01-02"); got != want {
+				t.Errorf("DateID(start) = %s, want %s", got, want)
+			}
+			if got, want := clock.DateID(end.Add(-time.Second)), start.Format("2006-01-02"); got != want {
+				t.Errorf("DateID(end-1s) = %s, want %s", got, want)
+			}
+			if got, want := clock.DateID(end), start.AddDate(0, 0, 1).Format("2006-01-02"); got != want {
+				t.Errorf("DateID(end) = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestChallengeClockResetInSkippedHour(t *testing.T) {
+	// 02:30 doesn't exist on 2026-03-08 in Los Angeles; the day rolls over
+	// when the clocks jump to 03:00 instead of being skipped.
+	clock := mustClock(t, "America/Los_Angeles", 2, 30)
+	before := time.Date(2026, 3, 8, 1, 59, 0, 0, clock.Location)
+
+	reset := clock.NextReset(before)
+	if got := clock.DateID(reset); got != "2026-03-08" {
+		t.Errorf("DateID(reset) = %s, want 2026-03-08", got)
+	}
+	if got := clock.DateID(before); got != "2026-03-07" {
+		t.Errorf("DateID(before) = %s, want 2026-03-07", got)
+	}
+	if got := reset.Sub(before); got != time.Minute {
+		t.Errorf("reset came %v after 01:59, want 1m", got)
+	}
+	if got := clock.NextReset(reset).Sub(reset); got != 24*time.Hour-30*time.Minute {
+		t.Errorf("day after the jump lasts %v, want 23h30m", got)
+	}
+}
+
+func TestParseResetTime(t *testing.T) {
+	hour, minute, err := ParseResetTime("07:45")
+	if err != nil || hour != 7 || minute != 45 {
+		t.Errorf("ParseResetTime(07:45) = %d, %d, %v", hour, minute, err)
+	}
+	for _, bad := range []string{"", "24:00", "7", "07:60", "noon"} {
+		if _, _, err := ParseResetTime(bad); err == nil {
+			t.Errorf("ParseResetTime(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package data
+
+import (
+	"crypto/sha256"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"monkeyy/typing"
+)
+
+var (
+	challengeSeed   string
+	challengeCorpus = mustCorpusSource()
+)
+
+// SetChallengeSeed sets the server secret mixed into every daily challenge.
+// Instances sharing a seed (and corpus) derive identical challenges.
+func SetChallengeSeed(seed string) {
+	challengeSeed = seed
+}
+
+// DailySentence deterministically derives the challenge text of words words
+// for dateID from the challenge seed and the embedded corpus, so any instance
+// can regenerate a day's text and past challenges can be reproduced for
+// audits with the word count they had (see DayWordCount). Editing the corpus
+// changes the text derived for every date.
+func DailySentence(dateID string, words int) string {
+	digest := sha256.Sum256([]byte(challengeSeed + "\x00" + dateID))
+	rng := rand.New(rand.NewChaCha8(digest))
+
+	sentences := []string{}
+	totalWords := 0
+	for _, i := range rng.Perm(len(challengeCorpus.sentences)) {
+		if totalWords >= words {
+			break
+		}
+		sentences = append(sentences, challengeCorpus.sentences[i])
+		totalWords += len(strings.Fields(challengeCorpus.sentences[i]))
+	}
+
+	return buildChallengeText(sentences, words)
+}
+
+// SentenceInfo describes today's challenge without giving its text away.
+type SentenceInfo struct {
+	DateID     string    `json:"date_id"`
+	Words      int       `json:"words"`
+	Characters int       `json:"characters"`
+	Players    int       `json:"players"`
+	ResetsAt   time.Time `json:"resets_at"`
+}
+
+// GetTodaysSentenceInfo returns the length of today's challenge, how many
+// players are on its leaderboard so far and when the next one comes out.
+func GetTodaysSentenceInfo() (*SentenceInfo, error) {
+	sentence, err := GetTodaysSentence()
+	if err != nil {
+		return nil, err
+	}
+	dateID := getCurrentDateID()
+	entries, err := GetTopEntries(dateID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &SentenceInfo{
+		DateID:     dateID,
+		Words:      len(strings.Fields(sentence)),
+		Characters: len(typing.Graphemes(sentence)),
+		Players:    len(entries),
+		ResetsAt:   challengeClock.NextReset(time.Now()).UTC(),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDailySentenceGolden pins the text derived for a few dates. Past
+// challenges are regenerated from the date and seed alone, so a change here
+// (a Go upgrade altering math/rand/v2's ChaCha8 or Perm, or an edit to the
+// corpus) would silently change history. If it fails, find out why before
+// updating the expected texts.
+func TestDailySentenceGolden(t *testing.T) {
+	previousSeed := challengeSeed
+	t.Cleanup(func() { challengeSeed = previousSeed })
+	SetChallengeSeed("golden-seed")
+
+	golden := map[string]string{
+		"2026-03-01": "a gentle wind carried the smell of pine needles through the open window. the roots of education are bitter, but the fruit is sweet. we are all in the gutter, but some of us are looking at the",
+		"2026-12-31": "strike while the iron is hot. there is no place like home. great minds think alike. the truth is rarely pure and never simple. still waters run deep. the future depends on what you do today. two wrongs",
+	}
+	for dateID, want := range golden {
+		if got := DailySentence(dateID, 38); got != want {
+			t.Errorf("DailySentence(%s) =\n%q\nwant\n%q", dateID, got, want)
+		}
+	}
+
+	SetChallengeSeed("another-seed")
+	if DailySentence("2026-03-01", 38) == golden["2026-03-01"] {
+		t.Error("the seed doesn't change the text")
+	}
+}
+
+func TestWordCountOnlyAffectsNewDays(t *testing.T) {
+	openTestStore(t)
+	previousCount := challengeWordCount
+	t.Cleanup(func() { challengeWordCount = previousCount })
+	today := getCurrentDateID()
+
+	SetWordCount(20)
+	sentence, err := GetTodaysSentence()
+	if err != nil {
+		t.Fatalf("GetTodaysSentence: %v", err)
+	}
+	if words := len(strings.Fields(sentence)); words != 20 {
+		t.Fatalf("today's text has %d words, want 20", words)
+	}
+
+	SetWordCount(30)
+	regenerated, err := GenerateTodaysSentence()
+	if err != nil {
+		t.Fatalf("GenerateTodaysSentence: %v", err)
+	}
+	if regenerated != sentence {
+		t.Errorf("changing the word count changed the text of a day already derived")
+	}
+	if words, err := DayWordCount(today); err != nil || words != 20 {
+		t.Errorf("DayWordCount(today) = %d, %v, want 20", words, err)
+	}
+	if words, err := DayWordCount("2099-01-01"); err != nil || words != 30 {
+		t.Errorf("DayWordCount for a day not yet derived = %d, %v, want 30", words, err)
+	}
+	if DailySentence(today, 20) != sentence {
+		t.Errorf("today's text can't be reproduced from its recorded word count")
+	}
+}
@@ -3,11 +3,11 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
-	"sort"
+	"math/rand/v2"
+	"monkeyy/typing"
 	"strings"
 	"time"
 
@@ -21,16 +21,52 @@ var (
 const (
 	storePrefix    = "store:"
 	sentencePrefix = "sentence:"
+	scorePrefix    = "score:"
+	rankPrefix     = "rank:"
+	wordsPrefix    = "words:"
 )
 
+const maxTxnRetries = 100
+
 var LONG_SENTENCE_COST = 60
 
+// challengeWordCount is the number of words in a challenge text.
+var challengeWordCount = 38
+
+// SetWordCount sets the number of words in generated challenge texts. Days
+// whose daily text has already been derived keep the count they had.
+func SetWordCount(n int) {
+	challengeWordCount = n
+}
+
+var ErrAlreadySubmitted = errors.New("user has already submitted a score today")
+
+var ErrNoSentence = errors.New("no challenge text")
+
+var ErrChallengeClosed = errors.New("challenge day no longer takes submissions")
+
+// resetGracePeriod is how long after the daily reset a run started before it
+// can still be submitted for the day it started on.
+const resetGracePeriod = 5 * time.Minute
+
+// RunStats are the metrics recorded for a finished typing run.
+type RunStats = typing.Stats
+
 type LeaderBoardEntry struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	WPM      int    `json:"wpm"`
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	WPM         int       `json:"wpm"`
+	Stats       RunStats  `json:"stats"`
+	Status      string    `json:"status,omitempty"`
+	FlagReason  string    `json:"flag_reason,omitempty"`
+	FlagDetail  string    `json:"flag_detail,omitempty"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	// Rank is filled in when the entry is read from a leaderboard.
+	Rank int `json:"rank,omitempty"`
 }
 
+// DBEntry is the legacy layout that kept a whole day in a single
+// `store:<date>` value. It is only read when migrating old databases.
 type DBEntry struct {
 	DateID    string             `json:"date_id"`
 	UserStats []LeaderBoardEntry `json:"user_stats"`
@@ -38,20 +74,17 @@ type DBEntry struct {
 
 type LeaderBoardResponse struct {
 	DateID             string             `json:"date_id"`
+	Sentence           string             `json:"sentence,omitempty"`
 	LeaderboardEntries []LeaderBoardEntry `json:"leaderboard_entries"`
+	Streaks            map[string]Streak  `json:"streaks,omitempty"`
 }
 
 func getCurrentDateID() string {
-	location, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		log.Printf("failed to load location: %v", err)
-		return time.Now().UTC().Format("2006-01-02")
-	}
-	return time.Now().In(location).Format("2006-01-02")
+	return challengeClock.DateID(time.Now())
 }
 
-func InitInMemoryStore() {
-	opts := badger.DefaultOptions("badger_db")
+func InitInMemoryStore(path string) {
+	opts := badger.DefaultOptions(path)
 	opts.Logger = nil
 	
 	var err error
@@ -60,16 +93,20 @@ func InitInMemoryStore() {
 		log.Fatalf("Failed to open Badger database: %v", err)
 	}
 
+	if err := migrateLegacyStores(); err != nil {
+		log.Printf("failed to migrate legacy leaderboards: %v", err)
+	}
+
+	if err := migrateUsernameKeys(); err != nil {
+		log.Printf("failed to migrate username reservations: %v", err)
+	}
+
+	if err := backfillAggregates(); err != nil {
+		log.Printf("failed to build aggregate leaderboards: %v", err)
+	}
+
 	if _, err := GetTodaysSentence(); err != nil {
-		log.Printf("Pre-generating today's sentence due to: %v", err)
-		sentence, err := GetLongSentence()
-		if err != nil {
-			log.Printf("failed to get long sentence: %v", err)
-			return
-		}
-		if err := InsertSentence(sentence); err != nil {
-			log.Printf("failed to insert sentence: %v", err)
-		}
+		log.Printf("failed to pre-generate today's sentence: %v", err)
 	}
 }
 
@@ -109,119 +146,395 @@ func getValue(key string, dest interface{}) error {
 	})
 }
 
-func GetUserChallengeStatus(userID string) (bool, error) {
-	dateId := getCurrentDateID()
-	key := storePrefix + dateId
-	
-	var todayEntry DBEntry
-	err := getValue(key, &todayEntry)
+func setTxnValue(txn *badger.Txn, key string, value interface{}) error {
+	jsonData, err := json.Marshal(value)
 	if err != nil {
-		return false, nil
+		return fmt.Errorf("failed to marshal value: %w", err)
 	}
+	return txn.Set([]byte(key), jsonData)
+}
+
+func getTxnValue(txn *badger.Txn, key string, dest interface{}) error {
+	item, err := txn.Get([]byte(key))
+	if err != nil {
+		return err
+	}
+	return item.Value(func(val []byte) error {
+		return json.Unmarshal(val, dest)
+	})
+}
 
-	for _, entry := range todayEntry.UserStats {
-		if entry.UserID == userID {
-			return true, nil
+// updateWithRetry runs fn in a read-write transaction, retrying with a
+// jittered exponential backoff when badger reports a conflict with a concurrent
+// transaction.
+func updateWithRetry(ctx context.Context, fn func(txn *badger.Txn) error) error {
+	var err error
+	for attempt := 0; attempt < maxTxnRetries; attempt++ {
+		err = db.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+
+		backoff := time.Millisecond << min(attempt, 10)
+		backoff = time.Duration(rand.Int64N(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
 		}
 	}
+	return fmt.Errorf("transaction kept conflicting after %d attempts: %w", maxTxnRetries, err)
+}
 
-	return false, nil
+func GetUserChallengeStatus(userID string) (bool, error) {
+	dateId := getCurrentDateID()
+
+	var entry LeaderBoardEntry
+	err := getValue(scoreKey(dateId, userID), &entry)
+	if err != nil {
+		return false, nil
+	}
+	return true, nil
 }
 
 func GetLeaderBoard() (*LeaderBoardResponse, error) {
 	dateId := getCurrentDateID()
-	key := storePrefix + dateId
-	
-	var todayEntry DBEntry
-	err := getValue(key, &todayEntry)
+
+	leaderBoard, err := GetLeaderBoardForDate(dateId)
 	if err != nil {
 		return &LeaderBoardResponse{
 			DateID:             dateId,
 			LeaderboardEntries: []LeaderBoardEntry{},
 		}, nil
 	}
+	return leaderBoard, nil
+}
 
-	leaderBoardEntries := make([]LeaderBoardEntry, len(todayEntry.UserStats))
-	copy(leaderBoardEntries, todayEntry.UserStats)
+// GetLeaderBoardForDate returns the leaderboard of any challenge day along
+// with that day's text and each player's current streak. Days nobody played
+// have an empty leaderboard.
+func GetLeaderBoardForDate(dateID string) (*LeaderBoardResponse, error) {
+	if _, err := ParseDateID(dateID); err != nil {
+		return nil, err
+	}
 
-	sort.Slice(leaderBoardEntries, func(i, j int) bool {
-		return leaderBoardEntries[i].WPM > leaderBoardEntries[j].WPM
-	})
+	entries, err := GetTopEntries(dateID, 0)
+	if err != nil {
+		return nil, err
+	}
+	sentence, err := GetSentence(dateID)
+	if err != nil && !errors.Is(err, ErrNoSentence) {
+		return nil, err
+	}
+	playerIDs := make([]string, len(entries))
+	for i, entry := range entries {
+		playerIDs[i] = entry.UserID
+	}
+	streaks, err := GetStreaks(playerIDs)
+	if err != nil {
+		return nil, err
+	}
 
 	return &LeaderBoardResponse{
-		DateID:             dateId,
-		LeaderboardEntries: leaderBoardEntries,
+		DateID:             dateID,
+		Sentence:           sentence,
+		LeaderboardEntries: entries,
+		Streaks:            streaks,
 	}, nil
 }
 
+// LeaderBoardPage is one page of a day's leaderboard, for screens that show
+// it a page at a time, along with where the player asking stands on it.
+type LeaderBoardPage struct {
+	DateID   string
+	Sentence string
+	Entries  []LeaderBoardEntry
+	// Total is the number of entries on the whole board, and LeaderID the
+	// player ranked first on it.
+	Total    int
+	LeaderID string
+	// Own is the player's entry with its rank, or nil if they have none.
+	// OwnRow is its index on the whole board.
+	Own     *LeaderBoardEntry
+	OwnRow  int
+	Streaks map[string]Streak
+}
+
+// GetLeaderBoardPage returns limit entries of a day's leaderboard from
+// offset, with playerID's own entry wherever it is on the board. Only the
+// scores shown are read, unlike GetLeaderBoardForDate.
+func GetLeaderBoardPage(dateID string, playerID string, offset int, limit int) (*LeaderBoardPage, error) {
+	if _, err := ParseDateID(dateID); err != nil {
+		return nil, err
+	}
+
+	var page entriesPage
+	err := db.View(func(txn *badger.Txn) error {
+		page = pageEntriesTxn(txn, dateID, playerID, offset, limit)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+	sentence, err := GetSentence(dateID)
+	if err != nil && !errors.Is(err, ErrNoSentence) {
+		return nil, err
+	}
+	playerIDs := make([]string, 0, len(page.entries)+1)
+	for _, entry := range page.entries {
+		playerIDs = append(playerIDs, entry.UserID)
+	}
+	if page.own != nil {
+		playerIDs = append(playerIDs, page.own.UserID)
+	}
+	streaks, err := GetStreaks(playerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LeaderBoardPage{
+		DateID:   dateID,
+		Sentence: sentence,
+		Entries:  page.entries,
+		Total:    page.total,
+		LeaderID: page.leaderID,
+		Own:      page.own,
+		OwnRow:   page.ownRow,
+		Streaks:  streaks,
+	}, nil
+}
+
+// Redact removes what only the game itself may show from a leaderboard
+// that is published elsewhere: the text of a challenge players may still be
+// about to type, and why runs were flagged.
+func (r *LeaderBoardResponse) Redact() {
+	if r.DateID >= getCurrentDateID() {
+		r.Sentence = ""
+	}
+	for i := range r.LeaderboardEntries {
+		r.LeaderboardEntries[i].FlagReason = ""
+		r.LeaderboardEntries[i].FlagDetail = ""
+	}
+}
+
+// GetChallengeDates returns the IDs of every day that has a challenge text,
+// oldest first.
+func GetChallengeDates() ([]string, error) {
+	dates := []string{}
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(sentencePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			dates = append(dates, strings.TrimPrefix(string(it.Item().Key()), sentencePrefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list challenge dates: %w", err)
+	}
+	return dates, nil
+}
+
+// GetTodaysSentence returns today's challenge text, deriving and storing it
+// first if no instance has done so yet.
 func GetTodaysSentence() (string, error) {
-	dateId := getCurrentDateID()
+	return GetDailySentence(getCurrentDateID())
+}
+
+// GetDailySentence returns the challenge text of dateId, deriving and storing
+// it first if no instance has done so yet. Screens that fetch the text keep
+// its date, so a run started before the reset is ranked on its own day.
+func GetDailySentence(dateId string) (string, error) {
 	key := sentencePrefix + dateId
-	
+
 	var sentence string
 	err := getValue(key, &sentence)
+	if err == nil {
+		return sentence, nil
+	}
+
+	sentence = DailySentence(dateId, challengeWordCount)
+	if err := insertDailySentence(dateId, sentence, challengeWordCount); err != nil {
+		return "", fmt.Errorf("no sentence for %s: %w", dateId, err)
+	}
+	return sentence, nil
+}
+
+// GetSentence returns the challenge text stored for a day.
+func GetSentence(dateID string) (string, error) {
+	var sentence string
+	err := db.View(func(txn *badger.Txn) error {
+		return getTxnValue(txn, sentencePrefix+dateID, &sentence)
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", fmt.Errorf("%w for %s", ErrNoSentence, dateID)
+	}
 	if err != nil {
-		return "", fmt.Errorf("no sentence for today")
+		return "", fmt.Errorf("failed to read sentence for %s: %w", dateID, err)
 	}
 	return sentence, nil
 }
 
-func SubmitSentence(ctx context.Context, userID string, username string, wpm int) error {
-	dateId := getCurrentDateID()
-	key := storePrefix + dateId
-	
-	var todayEntry DBEntry
-	err := getValue(key, &todayEntry)
+// GenerateTodaysSentence derives today's challenge text and stores it,
+// replacing anything stored for today before. A text derived for today
+// already keeps its word count.
+func GenerateTodaysSentence() (string, error) {
+	dateID := getCurrentDateID()
+	words, err := DayWordCount(dateID)
 	if err != nil {
-		todayEntry = DBEntry{
-			DateID:    dateId,
-			UserStats: []LeaderBoardEntry{},
-		}
+		return "", err
 	}
+	sentence := DailySentence(dateID, words)
+	if err := insertDailySentence(dateID, sentence, words); err != nil {
+		return "", err
+	}
+	return sentence, nil
+}
 
-	for _, entry := range todayEntry.UserStats {
-		if entry.UserID == userID {
-			return fmt.Errorf("user has already submitted a score today")
-		}
+// DayWordCount returns the number of words the daily text of dateID was
+// derived with, or the configured count for a day with no text yet.
+// DailySentence needs it to reproduce a past day's text.
+func DayWordCount(dateID string) (int, error) {
+	var words int
+	err := db.View(func(txn *badger.Txn) error {
+		return getTxnValue(txn, wordsPrefix+dateID, &words)
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return challengeWordCount, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read word count for %s: %w", dateID, err)
 	}
+	return words, nil
+}
+
+// SubmitSentence records a player's daily result along with its keystroke
+// timeline. The timeline is validated and the stats recomputed from it;
+// suspicious runs are flagged for review, and runs that can't be genuine are
+// stored for the record but left off the leaderboard and reported as
+// ErrRunRejected. Entries are ranked by net WPM.
+//
+// dateId is the day the run's text belongs to and startedAt when its first
+// key was typed, which must fall on that day. A run that crosses the daily
+// reset still counts for the day it started on if it is submitted within
+// resetGracePeriod of the reset.
+func SubmitSentence(ctx context.Context, dateId string, startedAt time.Time, userID string, username string, stats RunStats, keystrokes []Keystroke) error {
+	now := time.Now()
+	if challengeClock.DateID(startedAt) != dateId || startedAt.After(now) {
+		return fmt.Errorf("%w: a run started at %s is not on %s", ErrChallengeClosed, startedAt.Format(time.RFC3339), dateId)
+	}
+	if dateId != getCurrentDateID() && now.Sub(challengeClock.NextReset(startedAt)) > resetGracePeriod {
+		return fmt.Errorf("%w: %s", ErrChallengeClosed, dateId)
+	}
+	key := scoreKey(dateId, userID)
+
+	var verdict Verdict
+	err := updateWithRetry(ctx, func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(key))
+		if err == nil {
+			return ErrAlreadySubmitted
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		var sentence string
+		if err := getTxnValue(txn, sentencePrefix+dateId, &sentence); err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
 
-	todayEntry.UserStats = append(todayEntry.UserStats, LeaderBoardEntry{
-		UserID:   userID,
-		Username: username,
-		WPM:      wpm,
+		var serverStats RunStats
+		serverStats, verdict = ValidateRun(sentence, keystrokes, stats)
+
+		entry := LeaderBoardEntry{
+			UserID:      userID,
+			Username:    username,
+			WPM:         int(serverStats.NetWPM),
+			Stats:       serverStats,
+			Status:      verdict.Status,
+			FlagReason:  verdict.Reason,
+			FlagDetail:  verdict.Detail,
+			SubmittedAt: time.Now().UTC(),
+		}
+		if err := setTxnValue(txn, key, entry); err != nil {
+			return err
+		}
+		if err := txn.Set([]byte(historyKey(userID, dateId)), nil); err != nil {
+			return err
+		}
+
+		if err := setTxnValue(txn, replayKey(dateId, userID), Replay{
+			DateID:     dateId,
+			PlayerID:   userID,
+			Username:   username,
+			Text:       sentence,
+			Keystrokes: keystrokes,
+		}); err != nil {
+			return err
+		}
+
+		if verdict.Status != RunStatusOK {
+			if err := txn.Set([]byte(flagKey(dateId, userID)), []byte(dateId)); err != nil {
+				return err
+			}
+		} else if _, _, err := updateBestTxn(txn, userID, DailyMode, sentence, serverStats, keystrokes); err != nil {
+			return err
+		}
+		if verdict.Status == RunStatusRejected {
+			return nil
+		}
+		if err := addToAggregateTxn(txn, dateId, entry); err != nil {
+			return err
+		}
+		return txn.Set([]byte(rankKey(dateId, entry.WPM, entry.SubmittedAt.UnixNano(), userID)), nil)
 	})
+	if err != nil {
+		return err
+	}
+	if verdict.Status == RunStatusRejected {
+		return fmt.Errorf("%w: %s", ErrRunRejected, verdict.Detail)
+	}
+	return nil
+}
 
-	return setValue(key, todayEntry)
+// insertDailySentence stores a day's derived text with the word count it
+// was derived with.
+func insertDailySentence(dateID string, sentence string, words int) error {
+	err := db.Update(func(txn *badger.Txn) error {
+		if err := setTxnValue(txn, sentencePrefix+dateID, sentence); err != nil {
+			return err
+		}
+		return setTxnValue(txn, wordsPrefix+dateID, words)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save sentence: %w", err)
+	}
+	return nil
 }
 
 func InsertSentence(sentence string) error {
 	dateId := getCurrentDateID()
 	sentenceKey := sentencePrefix + dateId
-	storeKey := storePrefix + dateId
-	
+
 	if err := setValue(sentenceKey, sentence); err != nil {
 		return fmt.Errorf("failed to save sentence: %w", err)
 	}
-	
-	var todayEntry DBEntry
-	err := getValue(storeKey, &todayEntry)
-	if err != nil {
-		todayEntry = DBEntry{
-			DateID:    dateId,
-			UserStats: []LeaderBoardEntry{},
-		}
-		return setValue(storeKey, todayEntry)
-	}
-
 	return nil
 }
 
 func GetLongSentence() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
 	sentences := []string{}
 	totalWords := 0
 
-	for totalWords < 38 {
-		s, err := getRandomSentence()
+	for totalWords < challengeWordCount {
+		s, err := sentenceSource.RandomSentence(ctx)
 		if err != nil {
 			return "", fmt.Errorf("failed to get random sentence: %v", err)
 		}
@@ -230,11 +543,17 @@ func GetLongSentence() (string, error) {
 		totalWords = len(strings.Fields(allText))
 	}
 
+	return buildChallengeText(sentences, challengeWordCount), nil
+}
+
+// buildChallengeText joins sentences into a challenge text of at most
+// wordCount words and normalises punctuation and quotes for typing.
+func buildChallengeText(sentences []string, wordCount int) string {
 	finalSentence := strings.Join(sentences, " ")
 
 	words := strings.Fields(finalSentence)
-	if len(words) > 38 {
-		finalSentence = strings.Join(words[:38], " ")
+	if len(words) > wordCount {
+		finalSentence = strings.Join(words[:wordCount], " ")
 	}
 
 	finalSentence = strings.ReplaceAll(finalSentence, "\n", " ")
@@ -263,37 +582,5 @@ func GetLongSentence() (string, error) {
 	// replace double “ quotes with double quote space
 	finalSentence = strings.TrimSpace(finalSentence)
 
-	return finalSentence, nil
+	return typing.NormalizeText(finalSentence)
 }
-
-func getRandomSentence() (string, error) {
-	url := "http://thequoteshub.com/api/random-quote"
-	response, err := http.Get(url)
-	if err != nil {
-		return "", fmt.Errorf("failed to get random sentence: %v", err)
-	}
-	defer response.Body.Close()
-
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	var quote map[string]interface{}
-	err = json.Unmarshal(body, &quote)
-	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal response body: %v", err)
-	}
-
-	quoteText, exists := quote["text"]
-	if !exists {
-		return "", fmt.Errorf("failed to get quote text")
-	}
-
-	quoteString, ok := quoteText.(string)
-	if !ok {
-		return "", fmt.Errorf("failed to get quote text")
-	}
-
-	return quoteString, nil
-}
\ No newline at end of file
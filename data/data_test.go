@@ -0,0 +1,157 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"monkeyy/typing"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// openTestStore points the package at a fresh Badger database for the
+// duration of the test.
+func openTestStore(t *testing.T) {
+	t.Helper()
+	opts := badger.DefaultOptions(t.TempDir())
+	opts.Logger = nil
+
+	testDB, err := badger.Open(opts)
+	if err != nil {
+		t.Fatalf("failed to open badger: %v", err)
+	}
+	previous := db
+	db = testDB
+	t.Cleanup(func() {
+		testDB.Close()
+		db = previous
+	})
+}
+
+// humanTimeline types text key by key with irregular, human-like gaps.
+func humanTimeline(text string, seed uint64) []Keystroke {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	keystrokes := []Keystroke{}
+	offset := int64(0)
+	for i, char := range typing.Graphemes(text) {
+		if i > 0 {
+			offset += 60 + rng.Int64N(140)
+		}
+		keystrokes = append(keystrokes, Keystroke{OffsetMillis: offset, Key: char})
+	}
+	return keystrokes
+}
+
+func TestSubmitSentenceConcurrent(t *testing.T) {
+	openTestStore(t)
+
+	const text = "the quick brown fox jumps over the lazy dog"
+	if err := InsertSentence(text); err != nil {
+		t.Fatalf("InsertSentence: %v", err)
+	}
+
+	const players = 300
+	const attemptsPerPlayer = 2
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := map[string]int{}
+	var unexpected []error
+
+	for i := 0; i < players; i++ {
+		for attempt := 0; attempt < attemptsPerPlayer; attempt++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				userID := fmt.Sprintf("player-%d", i)
+				err := SubmitSentence(context.Background(), getCurrentDateID(), time.Now(), userID, userID, RunStats{}, humanTimeline(text, uint64(i)))
+
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					accepted[userID]++
+				case !errors.Is(err, ErrAlreadySubmitted):
+					unexpected = append(unexpected, err)
+				}
+			}(i)
+		}
+	}
+	wg.Wait()
+
+	if len(unexpected) > 0 {
+		t.Fatalf("unexpected submission errors: %v", unexpected[0])
+	}
+	for userID, count := range accepted {
+		if count != 1 {
+			t.Errorf("%s: accepted %d submissions, want 1", userID, count)
+		}
+	}
+
+	leaderboard, err := GetLeaderBoard()
+	if err != nil {
+		t.Fatalf("GetLeaderBoard: %v", err)
+	}
+	if len(leaderboard.LeaderboardEntries) != players {
+		t.Fatalf("leaderboard has %d entries, want %d", len(leaderboard.LeaderboardEntries), players)
+	}
+	seen := map[string]bool{}
+	for _, entry := range leaderboard.LeaderboardEntries {
+		if seen[entry.UserID] {
+			t.Errorf("duplicate entry for %s", entry.UserID)
+		}
+		seen[entry.UserID] = true
+	}
+}
+
+// resetClockAt sets a UTC challenge clock whose last reset was about ago
+// and returns that reset.
+func resetClockAt(t *testing.T, ago time.Duration) time.Time {
+	t.Helper()
+	previous := challengeClock
+	t.Cleanup(func() { challengeClock = previous })
+	reset := time.Now().UTC().Add(-ago).Truncate(time.Minute)
+	challengeClock = ChallengeClock{Location: time.UTC, ResetHour: reset.Hour(), ResetMinute: reset.Minute()}
+	return reset
+}
+
+func TestSubmitSentenceAcrossReset(t *testing.T) {
+	openTestStore(t)
+	reset := resetClockAt(t, time.Minute)
+	yesterday := challengeClock.DateID(reset.Add(-time.Second))
+	const oldText = "the quick brown fox jumps over the lazy dog"
+	seedDay(t, yesterday, oldText)
+
+	// the run started on yesterday's text just before the reset
+	if err := SubmitSentence(context.Background(), yesterday, reset.Add(-30*time.Second), "a", "alice", RunStats{}, humanTimeline(oldText, 1)); err != nil {
+		t.Fatalf("SubmitSentence: %v", err)
+	}
+	rank, total, err := GetPlayerRank(yesterday, "a")
+	if err != nil || rank != 1 || total != 1 {
+		t.Errorf("yesterday's rank = %d of %d (%v), want 1 of 1", rank, total, err)
+	}
+	if done, _ := GetUserChallengeStatus("a"); done {
+		t.Error("a run from yesterday counted as today's")
+	}
+
+	// yesterday's text is public by now, so a run started after the reset
+	// can't be ranked on it
+	if err := SubmitSentence(context.Background(), yesterday, reset.Add(time.Second), "b", "bob", RunStats{}, humanTimeline(oldText, 2)); !errors.Is(err, ErrChallengeClosed) {
+		t.Errorf("run started after the reset: err = %v, want ErrChallengeClosed", err)
+	}
+	if err := SubmitSentence(context.Background(), yesterday, time.Now().Add(time.Minute), "b", "bob", RunStats{}, humanTimeline(oldText, 2)); !errors.Is(err, ErrChallengeClosed) {
+		t.Errorf("run starting in the future: err = %v, want ErrChallengeClosed", err)
+	}
+
+	// nor can a run that went on past the grace period
+	reset = resetClockAt(t, resetGracePeriod+2*time.Minute)
+	yesterday = challengeClock.DateID(reset.Add(-time.Second))
+	seedDay(t, yesterday, oldText)
+	if err := SubmitSentence(context.Background(), yesterday, reset.Add(-time.Minute), "c", "carol", RunStats{}, humanTimeline(oldText, 3)); !errors.Is(err, ErrChallengeClosed) {
+		t.Errorf("run submitted after the grace period: err = %v, want ErrChallengeClosed", err)
+	}
+}
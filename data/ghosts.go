@@ -0,0 +1,92 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var ErrNoGhost = errors.New("no recorded run to race against")
+
+// Ghost is a recorded run that can be raced on its own text. Stats are the
+// ones recorded for the run.
+type Ghost struct {
+	Name       string      `json:"name"`
+	Mode       string      `json:"mode"`
+	Text       string      `json:"text"`
+	Keystrokes []Keystroke `json:"keystrokes"`
+	Stats      RunStats    `json:"stats"`
+}
+
+// GetPersonalBestGhost returns the player's best run in mode as a ghost.
+func GetPersonalBestGhost(playerID string, mode string) (*Ghost, error) {
+	var best PersonalBest
+	err := db.View(func(txn *badger.Txn) error {
+		return getTxnValue(txn, bestKey(playerID, mode), &best)
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) || (err == nil && len(best.Keystrokes) == 0) {
+		return nil, fmt.Errorf("%w in %s", ErrNoGhost, mode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read personal best: %w", err)
+	}
+	return &Ghost{
+		Name:       "your best",
+		Mode:       mode,
+		Text:       best.Text,
+		Keystrokes: best.Keystrokes,
+		Stats:      best.Stats,
+	}, nil
+}
+
+// GetDailyLeaderGhost returns the best run on a day's leaderboard as a
+// ghost. Runs flagged for review are passed over until an admin approves
+// them.
+func GetDailyLeaderGhost(dateID string) (*Ghost, error) {
+	var leader *LeaderBoardEntry
+	prefix := []byte(rankPrefix + dateID + ":")
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			playerID := rankKeyPlayerID(it.Item().Key(), prefix)
+			if playerID == "" {
+				continue
+			}
+			var entry LeaderBoardEntry
+			if err := getTxnValue(txn, scoreKey(dateID, playerID), &entry); err != nil {
+				continue
+			}
+			if entry.Status == RunStatusFlagged {
+				continue
+			}
+			leader = &entry
+			return nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+	if leader == nil {
+		return nil, fmt.Errorf("%w on %s", ErrNoGhost, dateID)
+	}
+
+	replay, err := GetReplay(dateID, leader.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoGhost, err)
+	}
+	return &Ghost{
+		Name:       leader.Username,
+		Mode:       DailyMode,
+		Text:       replay.Text,
+		Keystrokes: replay.Keystrokes,
+		Stats:      leader.Stats,
+	}, nil
+}
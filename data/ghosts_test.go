@@ -0,0 +1,56 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGhosts(t *testing.T) {
+	openTestStore(t)
+	ctx := context.Background()
+
+	if _, err := GetPersonalBestGhost("a", "words-10"); !errors.Is(err, ErrNoGhost) {
+		t.Errorf("ghost without a best: err = %v", err)
+	}
+	// bests set before keystrokes were kept can't be raced
+	if _, _, err := SavePersonalBest(ctx, "a", "words-10", "", RunStats{NetWPM: 40}, nil); err != nil {
+		t.Fatalf("SavePersonalBest: %v", err)
+	}
+	if _, err := GetPersonalBestGhost("a", "words-10"); !errors.Is(err, ErrNoGhost) {
+		t.Errorf("ghost without keystrokes: err = %v", err)
+	}
+
+	text := "quick brown fox"
+	if _, _, err := SavePersonalBest(ctx, "a", "words-10", text, RunStats{NetWPM: 60}, humanTimeline(text, 1)); err != nil {
+		t.Fatalf("SavePersonalBest: %v", err)
+	}
+	ghost, err := GetPersonalBestGhost("a", "words-10")
+	if err != nil {
+		t.Fatalf("GetPersonalBestGhost: %v", err)
+	}
+	if ghost.Text != text || ghost.Stats.NetWPM != 60 || len(ghost.Keystrokes) == 0 {
+		t.Errorf("personal best ghost = %+v", ghost)
+	}
+
+	dateID := "2026-03-01"
+	if _, err := GetDailyLeaderGhost(dateID); !errors.Is(err, ErrNoGhost) {
+		t.Errorf("ghost of an empty day: err = %v", err)
+	}
+	seedDay(t, dateID, text,
+		LeaderBoardEntry{UserID: "a", Username: "alice", WPM: 70, Stats: RunStats{NetWPM: 70}},
+		LeaderBoardEntry{UserID: "b", Username: "bob", WPM: 90, Stats: RunStats{NetWPM: 90}},
+		// a flagged run stays on the board but isn't raced until approved
+		LeaderBoardEntry{UserID: "c", Username: "carol", WPM: 200, Stats: RunStats{NetWPM: 200}, Status: RunStatusFlagged},
+	)
+	if err := setValue(replayKey(dateID, "b"), Replay{DateID: dateID, PlayerID: "b", Username: "bob", Text: text, Keystrokes: humanTimeline(text, 2)}); err != nil {
+		t.Fatalf("saving replay: %v", err)
+	}
+	ghost, err = GetDailyLeaderGhost(dateID)
+	if err != nil {
+		t.Fatalf("GetDailyLeaderGhost: %v", err)
+	}
+	if ghost.Name != "bob" || ghost.Mode != DailyMode || ghost.Text != text || ghost.Stats.NetWPM != 90 {
+		t.Errorf("daily leader ghost = %+v", ghost)
+	}
+}
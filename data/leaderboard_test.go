@@ -0,0 +1,250 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// seedDay stores a day's text and ranked scores directly, in submission
+// order, keeping the history index and aggregates up to date.
+func seedDay(t *testing.T, dateID string, text string, entries ...LeaderBoardEntry) {
+	t.Helper()
+	err := db.Update(func(txn *badger.Txn) error {
+		if err := setTxnValue(txn, sentencePrefix+dateID, text); err != nil {
+			return err
+		}
+		for i, entry := range entries {
+			if entry.SubmittedAt.IsZero() {
+				entry.SubmittedAt = time.Date(2026, 1, 1, 0, 0, i, 0, time.UTC)
+			}
+			if err := setTxnValue(txn, scoreKey(dateID, entry.UserID), entry); err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(historyKey(entry.UserID, dateID)), nil); err != nil {
+				return err
+			}
+			if err := addToAggregateTxn(txn, dateID, entry); err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(rankKey(dateID, entry.WPM, entry.SubmittedAt.UnixNano(), entry.UserID)), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("seeding %s: %v", dateID, err)
+	}
+}
+
+func TestGetLeaderBoardForDate(t *testing.T) {
+	openTestStore(t)
+	seedDay(t, "2026-03-01", "first day text",
+		LeaderBoardEntry{UserID: "a", Username: "alice", WPM: 70},
+		LeaderBoardEntry{UserID: "b", Username: "bob", WPM: 90},
+	)
+	seedDay(t, "2026-03-02", "second day text",
+		LeaderBoardEntry{UserID: "c", Username: "carol", WPM: 50},
+	)
+
+	board, err := GetLeaderBoardForDate("2026-03-01")
+	if err != nil {
+		t.Fatalf("GetLeaderBoardForDate: %v", err)
+	}
+	if board.Sentence != "first day text" || len(board.LeaderboardEntries) != 2 || board.LeaderboardEntries[0].Username != "bob" {
+		t.Errorf("2026-03-01 board = %+v", board)
+	}
+
+	board, err = GetLeaderBoardForDate("2026-02-27")
+	if err != nil {
+		t.Fatalf("GetLeaderBoardForDate for a day without a challenge: %v", err)
+	}
+	if board.Sentence != "" || len(board.LeaderboardEntries) != 0 {
+		t.Errorf("empty day board = %+v", board)
+	}
+
+	if _, err := GetLeaderBoardForDate("March 1st"); !errors.Is(err, ErrInvalidDate) {
+		t.Errorf("malformed date: err = %v, want ErrInvalidDate", err)
+	}
+
+	dates, err := GetChallengeDates()
+	if err != nil {
+		t.Fatalf("GetChallengeDates: %v", err)
+	}
+	if len(dates) != 2 || dates[0] != "2026-03-01" || dates[1] != "2026-03-02" {
+		t.Errorf("GetChallengeDates = %v", dates)
+	}
+}
+
+func TestLeaderboardRanksTies(t *testing.T) {
+	openTestStore(t)
+	// seedDay gives later entries later submission times
+	seedDay(t, "2026-03-01", "text",
+		LeaderBoardEntry{UserID: "a", Username: "alice", WPM: 80},
+		LeaderBoardEntry{UserID: "b", Username: "bob", WPM: 90},
+		LeaderBoardEntry{UserID: "c", Username: "carol", WPM: 80},
+		LeaderBoardEntry{UserID: "d", Username: "dave", WPM: 70},
+	)
+
+	entries, err := GetTopEntries("2026-03-01", 0)
+	if err != nil {
+		t.Fatalf("GetTopEntries: %v", err)
+	}
+	want := []struct {
+		username string
+		rank     int
+	}{{"bob", 1}, {"alice", 2}, {"carol", 2}, {"dave", 4}}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i, w := range want {
+		if entries[i].Username != w.username || entries[i].Rank != w.rank {
+			t.Errorf("entry %d = %s #%d, want %s #%d", i, entries[i].Username, entries[i].Rank, w.username, w.rank)
+		}
+	}
+
+	for _, w := range want {
+		playerID := w.username[:1]
+		rank, total, err := GetPlayerRank("2026-03-01", playerID)
+		if err != nil {
+			t.Fatalf("GetPlayerRank: %v", err)
+		}
+		if rank != w.rank || total != 4 {
+			t.Errorf("GetPlayerRank(%s) = %d of %d, want %d of 4", playerID, rank, total, w.rank)
+		}
+	}
+}
+
+func TestGetLeaderBoardPage(t *testing.T) {
+	openTestStore(t)
+	seedDay(t, "2026-03-01", "text",
+		LeaderBoardEntry{UserID: "a", Username: "alice", WPM: 80},
+		LeaderBoardEntry{UserID: "b", Username: "bob", WPM: 90},
+		LeaderBoardEntry{UserID: "c", Username: "carol", WPM: 80},
+		LeaderBoardEntry{UserID: "d", Username: "dave", WPM: 70},
+		LeaderBoardEntry{UserID: "e", Username: "erin", WPM: 60},
+	)
+
+	page, err := GetLeaderBoardPage("2026-03-01", "e", 1, 2)
+	if err != nil {
+		t.Fatalf("GetLeaderBoardPage: %v", err)
+	}
+	if page.Total != 5 || page.LeaderID != "b" || page.Sentence != "text" {
+		t.Errorf("page = %+v, want 5 entries led by b", page)
+	}
+	if len(page.Entries) != 2 || page.Entries[0].Username != "alice" || page.Entries[1].Username != "carol" || page.Entries[1].Rank != 2 {
+		t.Errorf("page entries = %+v, want alice and carol sharing #2", page.Entries)
+	}
+	if page.Own == nil || page.Own.Username != "erin" || page.Own.Rank != 5 || page.OwnRow != 4 {
+		t.Errorf("own entry = %+v at row %d, want erin #5 at row 4", page.Own, page.OwnRow)
+	}
+
+	page, err = GetLeaderBoardPage("2026-03-01", "nobody", 4, 10)
+	if err != nil {
+		t.Fatalf("GetLeaderBoardPage: %v", err)
+	}
+	if len(page.Entries) != 1 || page.Own != nil || page.OwnRow != -1 {
+		t.Errorf("last page = %+v, want erin alone and no own entry", page)
+	}
+}
+
+func TestShiftDateID(t *testing.T) {
+	tests := []struct {
+		dateID string
+		days   int
+		want   string
+	}{
+		{"2026-03-01", -1, "2026-02-28"},
+		{"2024-02-28", 1, "2024-02-29"},
+		{"2025-12-31", 1, "2026-01-01"},
+		{"2026-03-08", 0, "2026-03-08"},
+	}
+	for _, tt := range tests {
+		got, err := ShiftDateID(tt.dateID, tt.days)
+		if err != nil || got != tt.want {
+			t.Errorf("ShiftDateID(%s, %d) = %s, %v, want %s", tt.dateID, tt.days, got, err, tt.want)
+		}
+	}
+}
+
+func TestMigrateLegacyStores(t *testing.T) {
+	// a small memtable makes a day of a few thousand players too big for
+	// one transaction
+	opts := badger.DefaultOptions(t.TempDir()).WithMemTableSize(1 << 20).WithValueThreshold(1 << 10)
+	opts.Logger = nil
+	testDB, err := badger.Open(opts)
+	if err != nil {
+		t.Fatalf("failed to open badger: %v", err)
+	}
+	previous := db
+	db = testDB
+	t.Cleanup(func() {
+		testDB.Close()
+		db = previous
+	})
+
+	day := DBEntry{DateID: "2026-01-05"}
+	for i := range 5000 {
+		day.UserStats = append(day.UserStats, LeaderBoardEntry{
+			UserID:   fmt.Sprintf("player-%04d", i),
+			Username: fmt.Sprintf("player%04d", i),
+			WPM:      40 + i%80,
+		})
+	}
+	// a migration cut short had written the first player's score but not
+	// their rank key
+	if err := setValue(scoreKey(day.DateID, "player-0000"), day.UserStats[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := setValue(storePrefix+day.DateID, day); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(txn *badger.Txn) error {
+		return setTxnValue(txn, storePrefix+"2026-01-06", DBEntry{UserStats: []LeaderBoardEntry{
+			{UserID: "a", Username: "alice", WPM: 50},
+			{UserID: "b", Username: "bob", WPM: 50},
+		}})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateLegacyStores(); err != nil {
+		t.Fatalf("migrateLegacyStores: %v", err)
+	}
+
+	entries, err := GetTopEntries("2026-01-05", 0)
+	if err != nil {
+		t.Fatalf("GetTopEntries: %v", err)
+	}
+	if len(entries) != 5000 || entries[0].WPM != 119 {
+		t.Errorf("migrated %d entries led by %d WPM, want 5000 led by 119", len(entries), entries[0].WPM)
+	}
+
+	// the day without a date in its value takes it from the key, and ties
+	// keep the order of the old layout
+	entries, err = GetTopEntries("2026-01-06", 0)
+	if err != nil {
+		t.Fatalf("GetTopEntries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Username != "alice" || entries[1].Rank != 1 {
+		t.Errorf("2026-01-06 = %+v, want alice then bob, both #1", entries)
+	}
+
+	err = db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(storePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			t.Errorf("legacy key %s left behind", it.Item().Key())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
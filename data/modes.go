@@ -0,0 +1,127 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const bestPrefix = "best:"
+
+// DailyMode is the mode ID of the ranked daily challenge. Other modes are
+// named by the UI and only tracked as personal bests.
+const DailyMode = "daily"
+
+var challengeWords = corpusWords(challengeCorpus)
+
+// PersonalBest is a player's best run in one mode, by net WPM. The text and
+// keystrokes are kept so the run can be raced as a ghost; bests set before
+// they were recorded have neither.
+type PersonalBest struct {
+	Mode       string      `json:"mode"`
+	Stats      RunStats    `json:"stats"`
+	SetAt      time.Time   `json:"set_at"`
+	Text       string      `json:"text,omitempty"`
+	Keystrokes []Keystroke `json:"keystrokes,omitempty"`
+}
+
+func bestKey(playerID string, mode string) string {
+	return bestPrefix + playerID + ":" + mode
+}
+
+// corpusWords collects the distinct words of the corpus, lowercased and
+// stripped of punctuation.
+func corpusWords(source *CorpusSource) []string {
+	seen := map[string]bool{}
+	words := []string{}
+	for _, sentence := range source.sentences {
+		for _, word := range strings.Fields(strings.ToLower(sentence)) {
+			word = strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) })
+			if word == "" || seen[word] || strings.IndexFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' }) >= 0 {
+				continue
+			}
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// RandomWords returns n words picked at random from the embedded corpus, for
+// modes that run on generated text rather than sentences.
+func RandomWords(n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = challengeWords[rand.IntN(len(challengeWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+// SavePersonalBest records a finished run in mode, keeping it if it beats the
+// player's best. It returns the player's best after the run and whether this
+// run set it.
+func SavePersonalBest(ctx context.Context, playerID string, mode string, text string, stats RunStats, keystrokes []Keystroke) (PersonalBest, bool, error) {
+	var best PersonalBest
+	var isNew bool
+	err := updateWithRetry(ctx, func(txn *badger.Txn) error {
+		var err error
+		best, isNew, err = updateBestTxn(txn, playerID, mode, text, stats, keystrokes)
+		return err
+	})
+	if err != nil {
+		return PersonalBest{}, false, fmt.Errorf("failed to save personal best: %w", err)
+	}
+	return best, isNew, nil
+}
+
+func updateBestTxn(txn *badger.Txn, playerID string, mode string, text string, stats RunStats, keystrokes []Keystroke) (PersonalBest, bool, error) {
+	key := bestKey(playerID, mode)
+
+	var best PersonalBest
+	err := getTxnValue(txn, key, &best)
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return PersonalBest{}, false, err
+	}
+	if err == nil && best.Stats.NetWPM >= stats.NetWPM {
+		return best, false, nil
+	}
+
+	best = PersonalBest{Mode: mode, Stats: stats, SetAt: time.Now().UTC(), Text: text, Keystrokes: keystrokes}
+	return best, true, setTxnValue(txn, key, best)
+}
+
+// GetPersonalBests returns a player's best run in every mode they've played,
+// keyed by mode.
+func GetPersonalBests(playerID string) (map[string]PersonalBest, error) {
+	bests := map[string]PersonalBest{}
+	prefix := []byte(bestPrefix + playerID + ":")
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var best PersonalBest
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &best)
+			}); err != nil {
+				return err
+			}
+			bests[best.Mode] = best
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read personal bests: %w", err)
+	}
+	return bests, nil
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRandomWords(t *testing.T) {
+	words := strings.Fields(RandomWords(25))
+	if len(words) != 25 {
+		t.Fatalf("got %d words, want 25", len(words))
+	}
+	for _, word := range words {
+		if word != strings.ToLower(word) || strings.ContainsAny(word, ".,;:!?\"") {
+			t.Errorf("word %q is not a plain lowercase word", word)
+		}
+	}
+}
+
+func TestSavePersonalBest(t *testing.T) {
+	openTestStore(t)
+	ctx := context.Background()
+
+	runs := []struct {
+		mode   string
+		wpm    float64
+		isNew  bool
+		bestAt float64
+	}{
+		{"time-30", 60, true, 60},
+		{"time-30", 55, false, 60},
+		{"time-30", 72, true, 72},
+		{"words-25", 40, true, 40},
+	}
+	for _, run := range runs {
+		best, isNew, err := SavePersonalBest(ctx, "player-1", run.mode, "text", RunStats{NetWPM: run.wpm}, nil)
+		if err != nil {
+			t.Fatalf("SavePersonalBest: %v", err)
+		}
+		if isNew != run.isNew || best.Stats.NetWPM != run.bestAt {
+			t.Errorf("%s at %.0f WPM: best %.0f (new %v), want %.0f (new %v)", run.mode, run.wpm, best.Stats.NetWPM, isNew, run.bestAt, run.isNew)
+		}
+	}
+
+	if _, _, err := SavePersonalBest(ctx, "player-2", "time-30", "text", RunStats{NetWPM: 100}, nil); err != nil {
+		t.Fatalf("SavePersonalBest: %v", err)
+	}
+
+	bests, err := GetPersonalBests("player-1")
+	if err != nil {
+		t.Fatalf("GetPersonalBests: %v", err)
+	}
+	if len(bests) != 2 || bests["time-30"].Stats.NetWPM != 72 || bests["words-25"].Stats.NetWPM != 40 {
+		t.Errorf("GetPersonalBests = %+v, want time-30 at 72 and words-25 at 40", bests)
+	}
+}
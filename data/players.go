@@ -0,0 +1,224 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	playerKeyPrefix = "playerkey:"
+	playerPrefix    = "player:"
+	usernamePrefix  = "username:"
+)
+
+var ErrUsernameTaken = errors.New("username is already taken")
+
+var ErrNoPlayer = errors.New("no player for that identity")
+
+// Player is a registered player. Username is empty until the player claims
+// one on their first game.
+type Player struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// PlayerKey links an identity (a public key fingerprint, or an anonymous
+// user/IP pair for keyless logins) to a stable player ID.
+type PlayerKey struct {
+	PlayerID  string    `json:"player_id"`
+	Identity  string    `json:"identity"`
+	Anonymous bool      `json:"anonymous"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ResolvePlayerID returns the player ID bound to identity, creating a new
+// player the first time the identity is seen.
+func ResolvePlayerID(identity string, anonymous bool) (string, error) {
+	if identity == "" {
+		return "", fmt.Errorf("empty identity")
+	}
+	key := playerKeyPrefix + identity
+
+	var playerID string
+	err := db.Update(func(txn *badger.Txn) error {
+		var record PlayerKey
+		err := getTxnValue(txn, key, &record)
+		if err == nil {
+			playerID = record.PlayerID
+			return nil
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		now := time.Now().UTC()
+		record = PlayerKey{
+			PlayerID:  uuid.New().String(),
+			Identity:  identity,
+			Anonymous: anonymous,
+			CreatedAt: now,
+		}
+		if err := setTxnValue(txn, key, record); err != nil {
+			return err
+		}
+		playerID = record.PlayerID
+		return setTxnValue(txn, playerPrefix+record.PlayerID, Player{
+			ID:        record.PlayerID,
+			CreatedAt: now,
+			LastSeen:  now,
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve player: %w", err)
+	}
+	return playerID, nil
+}
+
+// LookupPlayerID returns the player ID bound to identity without creating
+// one, or ErrNoPlayer if the identity has never played.
+func LookupPlayerID(identity string) (string, error) {
+	var record PlayerKey
+	err := db.View(func(txn *badger.Txn) error {
+		return getTxnValue(txn, playerKeyPrefix+identity, &record)
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", ErrNoPlayer
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up player: %w", err)
+	}
+	return record.PlayerID, nil
+}
+
+// GetPlayer returns the registered player with the given ID.
+func GetPlayer(playerID string) (*Player, error) {
+	var player Player
+	if err := getValue(playerPrefix+playerID, &player); err != nil {
+		return nil, fmt.Errorf("player not found")
+	}
+	return &player, nil
+}
+
+// TouchPlayer records that the player was just seen and returns the
+// updated record.
+func TouchPlayer(playerID string) (*Player, error) {
+	var player Player
+	err := db.Update(func(txn *badger.Txn) error {
+		if err := getTxnValue(txn, playerPrefix+playerID, &player); err != nil {
+			return err
+		}
+		player.LastSeen = time.Now().UTC()
+		return setTxnValue(txn, playerPrefix+playerID, player)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update player: %w", err)
+	}
+	return &player, nil
+}
+
+// ClaimUsername reserves username for the player. Usernames are unique
+// regardless of case. A player who already has a username keeps it, and the
+// existing record is returned unchanged.
+func ClaimUsername(playerID string, username string) (*Player, error) {
+	var player Player
+	err := db.Update(func(txn *badger.Txn) error {
+		if err := getTxnValue(txn, playerPrefix+playerID, &player); err != nil {
+			return err
+		}
+		if player.Username != "" {
+			return nil
+		}
+
+		reservedKey := usernamePrefix + foldUsername(username)
+		var ownerID string
+		err := getTxnValue(txn, reservedKey, &ownerID)
+		if err == nil && ownerID != playerID {
+			return ErrUsernameTaken
+		}
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		player.Username = username
+		player.LastSeen = time.Now().UTC()
+		if err := setTxnValue(txn, reservedKey, playerID); err != nil {
+			return err
+		}
+		return setTxnValue(txn, playerPrefix+playerID, player)
+	})
+	if err != nil {
+		if errors.Is(err, ErrUsernameTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to claim username: %w", err)
+	}
+	return &player, nil
+}
+
+// foldUsername returns the form usernames are reserved under. Names that
+// differ only in case, such as "STRASSE" and "straße", or in how the same
+// characters are encoded fold to the same string.
+func foldUsername(username string) string {
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(username)))
+}
+
+// migrateUsernameKeys moves reservations made before usernames were case
+// folded, which only lowercased them, to their folded key. A reservation
+// whose folded key is already taken stays where it is.
+func migrateUsernameKeys() error {
+	type reservation struct {
+		key     string
+		ownerID string
+	}
+	var stale []reservation
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(usernamePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := string(it.Item().KeyCopy(nil))
+			if key == usernamePrefix+foldUsername(strings.TrimPrefix(key, usernamePrefix)) {
+				continue
+			}
+			var ownerID string
+			if err := getTxnValue(txn, key, &ownerID); err != nil {
+				return err
+			}
+			stale = append(stale, reservation{key: key, ownerID: ownerID})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range stale {
+		folded := usernamePrefix + foldUsername(strings.TrimPrefix(r.key, usernamePrefix))
+		err := db.Update(func(txn *badger.Txn) error {
+			if _, err := txn.Get([]byte(folded)); err == nil {
+				return nil
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+			if err := setTxnValue(txn, folded, r.ownerID); err != nil {
+				return err
+			}
+			return txn.Delete([]byte(r.key))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", r.key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClaimUsernameFoldsCase(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string
+		second string
+	}{
+		{"ascii case", "Alice_01", "aLICE_01"},
+		{"sharp s", "Straße", "STRASSE"},
+		{"kelvin sign", "Kelvin", "\u212Aelvin"},
+		{"final sigma", "ΟΔΥΣΣΕΥΣ", "οδυσσευς"},
+		{"decomposed accent", "José", "Jose\u0301"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestStore(t)
+			first, _ := ResolvePlayerID("pubkey:first", false)
+			second, _ := ResolvePlayerID("pubkey:second", false)
+
+			if _, err := ClaimUsername(first, tt.first); err != nil {
+				t.Fatalf("ClaimUsername(%q): %v", tt.first, err)
+			}
+			if _, err := ClaimUsername(second, tt.second); !errors.Is(err, ErrUsernameTaken) {
+				t.Errorf("ClaimUsername(%q) after %q = %v, want ErrUsernameTaken", tt.second, tt.first, err)
+			}
+		})
+	}
+}
+
+func TestClaimUsername(t *testing.T) {
+	openTestStore(t)
+	first, _ := ResolvePlayerID("pubkey:first", false)
+	second, _ := ResolvePlayerID("pubkey:second", false)
+
+	if _, err := ClaimUsername(first, "alice"); err != nil {
+		t.Fatalf("ClaimUsername: %v", err)
+	}
+	player, err := ClaimUsername(first, "alice2")
+	if err != nil || player.Username != "alice" {
+		t.Errorf("claiming a second name = %+v, %v, want to keep alice", player, err)
+	}
+	if _, err := ClaimUsername(second, "alice2"); err != nil {
+		t.Errorf("alice2 is still free, got %v", err)
+	}
+	if _, err := ClaimUsername("no-such-player", "bob"); err == nil {
+		t.Error("claimed a name for a player that doesn't exist")
+	}
+}
+
+func TestLookupPlayerID(t *testing.T) {
+	openTestStore(t)
+	if _, err := LookupPlayerID("pubkey:new"); !errors.Is(err, ErrNoPlayer) {
+		t.Fatalf("unknown identity: err = %v, want ErrNoPlayer", err)
+	}
+	// looking up doesn't register the identity
+	if _, err := LookupPlayerID("pubkey:new"); !errors.Is(err, ErrNoPlayer) {
+		t.Errorf("second lookup: err = %v, want ErrNoPlayer", err)
+	}
+
+	playerID, err := ResolvePlayerID("pubkey:new", false)
+	if err != nil {
+		t.Fatalf("ResolvePlayerID: %v", err)
+	}
+	if got, err := LookupPlayerID("pubkey:new"); err != nil || got != playerID {
+		t.Errorf("LookupPlayerID = %q, %v, want %q", got, err, playerID)
+	}
+}
+
+func TestMigrateUsernameKeys(t *testing.T) {
+	openTestStore(t)
+	first, _ := ResolvePlayerID("pubkey:first", false)
+	second, _ := ResolvePlayerID("pubkey:second", false)
+
+	// reserved before folding, when names were only lowercased
+	if err := setValue(usernamePrefix+"straße", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateUsernameKeys(); err != nil {
+		t.Fatalf("migrateUsernameKeys: %v", err)
+	}
+	if _, err := ClaimUsername(second, "STRASSE"); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("ClaimUsername after migration = %v, want ErrUsernameTaken", err)
+	}
+	var ownerID string
+	if err := getValue(usernamePrefix+"strasse", &ownerID); err != nil || ownerID != first {
+		t.Errorf("folded reservation = %q, %v, want %q", ownerID, err, first)
+	}
+}
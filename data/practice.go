@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const practicePrefix = "practice:"
+
+// PracticeResult is an unranked run, kept in the player's practice history
+// apart from the daily leaderboard.
+type PracticeResult struct {
+	PlayerID string    `json:"player_id"`
+	Mode     string    `json:"mode"`
+	Text     string    `json:"text"`
+	Stats    RunStats  `json:"stats"`
+	PlayedAt time.Time `json:"played_at"`
+}
+
+func practiceKey(playerID string, playedAt time.Time) string {
+	return fmt.Sprintf("%s%s:%020d", practicePrefix, playerID, playedAt.UnixNano())
+}
+
+// RecordPracticeRun adds an unranked run to the player's practice history.
+func RecordPracticeRun(ctx context.Context, playerID string, mode string, text string, stats RunStats) error {
+	result := PracticeResult{
+		PlayerID: playerID,
+		Mode:     mode,
+		Text:     text,
+		Stats:    stats,
+		PlayedAt: time.Now().UTC(),
+	}
+	err := updateWithRetry(ctx, func(txn *badger.Txn) error {
+		return setTxnValue(txn, practiceKey(playerID, result.PlayedAt), result)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record practice run: %w", err)
+	}
+	return nil
+}
+
+// GetPracticeHistory returns a player's practice runs, most recent first. A
+// limit of 0 returns them all.
+func GetPracticeHistory(playerID string, limit int) ([]PracticeResult, error) {
+	history := []PracticeResult{}
+	prefix := []byte(practicePrefix + playerID + ":")
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(append(prefix, 0xff)); it.Valid(); it.Next() {
+			var result PracticeResult
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &result)
+			}); err != nil {
+				return err
+			}
+			history = append(history, result)
+			if limit > 0 && len(history) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read practice history: %w", err)
+	}
+	return history, nil
+}
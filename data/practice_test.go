@@ -0,0 +1,39 @@
+package data
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPracticeHistoryIsSeparateFromLeaderboard(t *testing.T) {
+	openTestStore(t)
+	ctx := context.Background()
+
+	for _, wpm := range []float64{50, 60, 70} {
+		if err := RecordPracticeRun(ctx, "player-1", "practice", "some text", RunStats{NetWPM: wpm}); err != nil {
+			t.Fatalf("RecordPracticeRun: %v", err)
+		}
+	}
+	if err := RecordPracticeRun(ctx, "player-2", "practice", "some text", RunStats{NetWPM: 90}); err != nil {
+		t.Fatalf("RecordPracticeRun: %v", err)
+	}
+
+	history, err := GetPracticeHistory("player-1", 2)
+	if err != nil {
+		t.Fatalf("GetPracticeHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].Stats.NetWPM != 70 || history[1].Stats.NetWPM != 60 {
+		t.Errorf("history = %+v, want the two most recent runs, newest first", history)
+	}
+
+	if done, _ := GetUserChallengeStatus("player-1"); done {
+		t.Error("practice run used up the daily challenge")
+	}
+	leaderboard, err := GetLeaderBoard()
+	if err != nil {
+		t.Fatalf("GetLeaderBoard: %v", err)
+	}
+	if len(leaderboard.LeaderboardEntries) != 0 {
+		t.Errorf("practice runs reached the daily leaderboard: %+v", leaderboard.LeaderboardEntries)
+	}
+}
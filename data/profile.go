@@ -0,0 +1,145 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// PlayerResult is a player's daily challenge result on one day. Rank is 0
+// for runs that were rejected and so never made the leaderboard.
+type PlayerResult struct {
+	DateID   string  `json:"date_id"`
+	WPM      int     `json:"wpm"`
+	Accuracy float64 `json:"accuracy"`
+	Rank     int     `json:"rank"`
+	Total    int     `json:"total"`
+	Status   string  `json:"status,omitempty"`
+}
+
+// PlayerProfile sums up a player's daily challenge results. Percentile is
+// the share of other players whose best day is slower than this player's.
+type PlayerProfile struct {
+	PlayerID   string         `json:"player_id"`
+	Username   string         `json:"username"`
+	DaysPlayed int            `json:"days_played"`
+	BestWPM    int            `json:"best_wpm"`
+	BestDateID string         `json:"best_date_id"`
+	AverageWPM float64        `json:"average_wpm"`
+	Percentile float64        `json:"percentile"`
+	Results    []PlayerResult `json:"results"`
+}
+
+// MaxHistoryResults bounds the days GetPlayerHistory returns at once, since
+// ranking each day reads that day's rank index.
+const MaxHistoryResults = 60
+
+// GetPlayerHistory returns a player's daily results, newest first. A limit
+// of zero, or one above MaxHistoryResults, returns the MaxHistoryResults
+// most recent days.
+func GetPlayerHistory(playerID string, limit int) ([]PlayerResult, error) {
+	if limit <= 0 || limit > MaxHistoryResults {
+		limit = MaxHistoryResults
+	}
+	results := []PlayerResult{}
+	prefix := []byte(historyPrefix + playerID + ":")
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Reverse = true
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(append(prefix, 0xff)); it.Valid(); it.Next() {
+			dateID := string(it.Item().Key()[len(prefix):])
+			var entry LeaderBoardEntry
+			if err := getTxnValue(txn, scoreKey(dateID, playerID), &entry); err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+				return err
+			}
+
+			result := PlayerResult{
+				DateID:   dateID,
+				WPM:      entry.WPM,
+				Accuracy: entry.Stats.Accuracy,
+				Status:   entry.Status,
+			}
+			if countsOnLeaderboard(entry.Status) {
+				result.Rank, result.Total = playerRankTxn(txn, dateID, playerID)
+			}
+			results = append(results, result)
+			if len(results) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history for %s: %w", playerID, err)
+	}
+	return results, nil
+}
+
+// GetPlayerProfile returns a player's profile with up to limit of their most
+// recent results.
+func GetPlayerProfile(playerID string, limit int) (*PlayerProfile, error) {
+	aggregate, err := GetPlayerAggregate(playerID)
+	if err != nil {
+		return nil, err
+	}
+	results, err := GetPlayerHistory(playerID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &PlayerProfile{
+		PlayerID:   playerID,
+		Username:   aggregate.Username,
+		DaysPlayed: aggregate.DaysPlayed,
+		BestWPM:    aggregate.BestWPM,
+		BestDateID: aggregate.BestDateID,
+		Results:    results,
+	}
+	if aggregate.DaysPlayed == 0 {
+		return profile, nil
+	}
+	profile.AverageWPM = float64(aggregate.TotalWPM) / float64(aggregate.DaysPlayed)
+
+	// the best day index is ordered fastest first, so every key past the
+	// player's own score is a slower player
+	own := bestDayKey(aggregate.BestWPM, playerID)
+	score := len(bestDayPrefix) + 6
+	slower, others := 0, 0
+	err = db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(bestDayPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := string(it.Item().Key())
+			if key == own {
+				continue
+			}
+			others++
+			if key[:score] > own[:score] {
+				slower++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank %s: %w", playerID, err)
+	}
+	profile.Percentile = 100
+	if others > 0 {
+		profile.Percentile = 100 * float64(slower) / float64(others)
+	}
+	return profile, nil
+}
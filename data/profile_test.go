@@ -0,0 +1,75 @@
+package data
+
+import "testing"
+
+func TestGetPlayerProfile(t *testing.T) {
+	openTestStore(t)
+	seedAggregateFixture(t)
+
+	profile, err := GetPlayerProfile("a", 0)
+	if err != nil {
+		t.Fatalf("GetPlayerProfile: %v", err)
+	}
+	if profile.Username != "alice" || profile.DaysPlayed != 3 || profile.BestWPM != 80 || profile.AverageWPM != 70 {
+		t.Errorf("profile = %+v, want alice, 3 days, best 80, average 70", profile)
+	}
+	// carol's best is 120 and bob's 100, so alice is faster than neither
+	if profile.Percentile != 0 {
+		t.Errorf("percentile = %v, want 0", profile.Percentile)
+	}
+
+	wantDates := []string{daysAgo(t, 0), daysAgo(t, 1), daysAgo(t, 2)}
+	if len(profile.Results) != len(wantDates) {
+		t.Fatalf("got %d results, want %d", len(profile.Results), len(wantDates))
+	}
+	for i, result := range profile.Results {
+		if result.DateID != wantDates[i] {
+			t.Errorf("result %d is from %s, want %s", i, result.DateID, wantDates[i])
+		}
+	}
+	// today alice (60) is behind bob (100)
+	if today := profile.Results[0]; today.WPM != 60 || today.Rank != 2 || today.Total != 2 {
+		t.Errorf("today = %+v, want 60 WPM, rank 2 of 2", today)
+	}
+
+	carol, err := GetPlayerProfile("c", 1)
+	if err != nil {
+		t.Fatalf("GetPlayerProfile: %v", err)
+	}
+	if carol.Percentile != 100 || len(carol.Results) != 1 || carol.Results[0].DateID != daysAgo(t, 8) {
+		t.Errorf("carol = %+v, want percentile 100 and only her latest day", carol)
+	}
+
+	// rejecting carol's 120 WPM day moves her best to 50, behind alice
+	if err := ReviewRun(daysAgo(t, 40), "c", false); err != nil {
+		t.Fatalf("ReviewRun: %v", err)
+	}
+	if profile, err = GetPlayerProfile("a", 1); err != nil || profile.Percentile != 50 {
+		t.Errorf("percentile after the review = %v (%v), want 50", profile.Percentile, err)
+	}
+
+	nobody, err := GetPlayerProfile("nobody", 0)
+	if err != nil {
+		t.Fatalf("GetPlayerProfile: %v", err)
+	}
+	if nobody.DaysPlayed != 0 || len(nobody.Results) != 0 {
+		t.Errorf("unknown player has results: %+v", nobody)
+	}
+}
+
+func TestGetPlayerHistoryLimit(t *testing.T) {
+	openTestStore(t)
+	for i := MaxHistoryResults; i >= 0; i-- {
+		seedDay(t, daysAgo(t, i), "text", LeaderBoardEntry{UserID: "a", Username: "alice", WPM: 50})
+	}
+
+	for _, limit := range []int{0, MaxHistoryResults + 1, 1000} {
+		results, err := GetPlayerHistory("a", limit)
+		if err != nil {
+			t.Fatalf("GetPlayerHistory: %v", err)
+		}
+		if len(results) != MaxHistoryResults || results[0].DateID != daysAgo(t, 0) {
+			t.Errorf("limit %d: got %d results from %s, want the latest %d", limit, len(results), results[0].DateID, MaxHistoryResults)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package data
+
+import (
+	"fmt"
+	"monkeyy/typing"
+)
+
+const (
+	replayPrefix = "replay:"
+
+	// BackspaceKey marks a backspace in a keystroke timeline.
+	BackspaceKey = typing.BackspaceKey
+)
+
+// Keystroke is one key press in a run, timed from the first keystroke.
+type Keystroke = typing.Keystroke
+
+// Replay is the full keystroke timeline of a submitted daily run.
+type Replay struct {
+	DateID     string      `json:"date_id"`
+	PlayerID   string      `json:"player_id"`
+	Username   string      `json:"username"`
+	Text       string      `json:"text"`
+	Keystrokes []Keystroke `json:"keystrokes"`
+}
+
+func replayKey(dateID string, playerID string) string {
+	return replayPrefix + dateID + ":" + playerID
+}
+
+// GetReplay returns the recorded run of a player on a given day.
+func GetReplay(dateID string, playerID string) (*Replay, error) {
+	var replay Replay
+	if err := getValue(replayKey(dateID, playerID), &replay); err != nil {
+		return nil, fmt.Errorf("no replay for %s on %s", playerID, dateID)
+	}
+	return &replay, nil
+}
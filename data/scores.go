@@ -0,0 +1,285 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// maxRankWPM bounds the WPM values the rank index can order. Scores are
+// stored inverted so that a forward prefix scan yields the fastest first.
+const maxRankWPM = 999999
+
+// scoreKey is the per-player leaderboard entry for a day.
+func scoreKey(dateID string, playerID string) string {
+	return scorePrefix + dateID + ":" + playerID
+}
+
+// rankKey is the secondary index over a day's scores, ordered by WPM
+// (descending) and then by order (ascending, normally the submission time).
+func rankKey(dateID string, wpm int, order int64, playerID string) string {
+	if wpm < 0 {
+		wpm = 0
+	}
+	if wpm > maxRankWPM {
+		wpm = maxRankWPM
+	}
+	if order < 0 {
+		order = 0
+	}
+	return fmt.Sprintf("%s%s:%06d:%020d:%s", rankPrefix, dateID, maxRankWPM-wpm, order, playerID)
+}
+
+func rankKeyPlayerID(key []byte, prefix []byte) string {
+	parts := strings.SplitN(string(bytes.TrimPrefix(key, prefix)), ":", 3)
+	if len(parts) != 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// GetTopEntries returns the best entries for a day in ranking order, with
+// their ranks filled in. Players with the same WPM share a rank and are
+// listed in the order they submitted. A limit of zero returns every entry.
+func GetTopEntries(dateID string, limit int) ([]LeaderBoardEntry, error) {
+	entries := []LeaderBoardEntry{}
+	prefix := []byte(rankPrefix + dateID + ":")
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			playerID := rankKeyPlayerID(it.Item().Key(), prefix)
+			if playerID == "" {
+				continue
+			}
+
+			var entry LeaderBoardEntry
+			if err := getTxnValue(txn, scoreKey(dateID, playerID), &entry); err != nil {
+				log.Printf("rank index points at missing score %s/%s: %v", dateID, playerID, err)
+				continue
+			}
+			entry.Rank = len(entries) + 1
+			if previous := len(entries) - 1; previous >= 0 && entries[previous].WPM == entry.WPM {
+				entry.Rank = entries[previous].Rank
+			}
+			entries = append(entries, entry)
+			if limit > 0 && len(entries) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+	return entries, nil
+}
+
+// entriesPage is one page of a day's leaderboard read by pageEntriesTxn.
+type entriesPage struct {
+	entries  []LeaderBoardEntry
+	total    int
+	leaderID string
+	own      *LeaderBoardEntry
+	ownRow   int
+}
+
+// pageEntriesTxn reads limit entries of a day's leaderboard from offset,
+// ranked as GetTopEntries ranks them, along with playerID's own entry. The
+// rest of the board is only counted from the rank index, so a page costs
+// the same however many players there are beyond it.
+func pageEntriesTxn(txn *badger.Txn, dateID string, playerID string, offset int, limit int) entriesPage {
+	page := entriesPage{entries: []LeaderBoardEntry{}, ownRow: -1}
+	tieRank, tieScore := 0, ""
+	prefix := []byte(rankPrefix + dateID + ":")
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		row := page.total
+		page.total++
+		score, _, _ := strings.Cut(string(bytes.TrimPrefix(it.Item().Key(), prefix)), ":")
+		if score != tieScore {
+			tieRank, tieScore = page.total, score
+		}
+
+		rowPlayerID := rankKeyPlayerID(it.Item().Key(), prefix)
+		if row == 0 {
+			page.leaderID = rowPlayerID
+		}
+		onPage := row >= offset && row < offset+limit
+		own := playerID != "" && rowPlayerID == playerID
+		if rowPlayerID == "" || (!onPage && !own) {
+			continue
+		}
+
+		var entry LeaderBoardEntry
+		if err := getTxnValue(txn, scoreKey(dateID, rowPlayerID), &entry); err != nil {
+			log.Printf("rank index points at missing score %s/%s: %v", dateID, rowPlayerID, err)
+			continue
+		}
+		entry.Rank = tieRank
+		if onPage {
+			page.entries = append(page.entries, entry)
+		}
+		if own {
+			page.own = &entry
+			page.ownRow = row
+		}
+	}
+	return page
+}
+
+// GetPlayerRank returns the player's 1-based rank on a day's leaderboard and
+// the number of entries on it, ranking ties as GetTopEntries does. The rank
+// is 0 if the player has no entry.
+func GetPlayerRank(dateID string, playerID string) (int, int, error) {
+	var rank, total int
+	err := db.View(func(txn *badger.Txn) error {
+		rank, total = playerRankTxn(txn, dateID, playerID)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+	return rank, total, nil
+}
+
+func playerRankTxn(txn *badger.Txn, dateID string, playerID string) (int, int) {
+	rank, total := 0, 0
+	tieRank, tieScore := 0, ""
+	prefix := []byte(rankPrefix + dateID + ":")
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		total++
+		score, _, _ := strings.Cut(string(bytes.TrimPrefix(it.Item().Key(), prefix)), ":")
+		if score != tieScore {
+			tieRank, tieScore = total, score
+		}
+		if rank == 0 && rankKeyPlayerID(it.Item().Key(), prefix) == playerID {
+			rank = tieRank
+		}
+	}
+	return rank, total
+}
+
+// migrateLegacyStores moves days kept in the old single-value `store:<date>`
+// layout onto per-player score keys and the rank index, deleting each blob
+// once its entries have been copied. Entries keep their original submission
+// order as the tiebreak, since the old layout had no timestamps.
+func migrateLegacyStores() error {
+	var legacyKeys []string
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(storePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			legacyKeys = append(legacyKeys, string(it.Item().KeyCopy(nil)))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range legacyKeys {
+		if err := migrateLegacyDay(key); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", key, err)
+		}
+		log.Printf("Migrated legacy leaderboard %s", key)
+	}
+	return nil
+}
+
+// migrateLegacyDay copies one legacy day through a write batch, which splits
+// the writes over as many transactions as a busy day needs. The blob is only
+// deleted once every entry has been written. An interrupted migration
+// starts the day again: entries already in the rank index are skipped, and
+// entries whose score was written without its rank key are written again.
+func migrateLegacyDay(key string) error {
+	var day DBEntry
+	var skip map[string]bool
+	err := db.View(func(txn *badger.Txn) error {
+		if err := getTxnValue(txn, key, &day); err != nil {
+			return err
+		}
+		if day.DateID == "" {
+			day.DateID = strings.TrimPrefix(key, storePrefix)
+		}
+		skip = map[string]bool{}
+		for i, entry := range day.UserStats {
+			if _, err := txn.Get([]byte(rankKey(day.DateID, entry.WPM, int64(i), entry.UserID))); err == nil {
+				skip[entry.UserID] = true
+				continue
+			}
+			// a score that isn't this entry's came from somewhere else and
+			// wins, as it did before the migration was batched
+			var existing LeaderBoardEntry
+			err := getTxnValue(txn, scoreKey(day.DateID, entry.UserID), &existing)
+			if err == nil && !sameEntry(existing, entry) {
+				skip[entry.UserID] = true
+			} else if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	batch := db.NewWriteBatch()
+	defer batch.Cancel()
+	for i, entry := range day.UserStats {
+		if skip[entry.UserID] {
+			continue
+		}
+		skip[entry.UserID] = true
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal value: %w", err)
+		}
+		if err := batch.Set([]byte(scoreKey(day.DateID, entry.UserID)), value); err != nil {
+			return err
+		}
+		if err := batch.Set([]byte(rankKey(day.DateID, entry.WPM, int64(i), entry.UserID)), nil); err != nil {
+			return err
+		}
+	}
+	if err := batch.Flush(); err != nil {
+		return err
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(key))
+	})
+}
+
+func sameEntry(a LeaderBoardEntry, b LeaderBoardEntry) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
@@ -0,0 +1,183 @@
+package data
+
+import (
+	"bufio"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//go:embed corpus/*.txt
+var corpusFS embed.FS
+
+// SentenceSource supplies raw sentences that GetLongSentence stitches into a
+// challenge text.
+type SentenceSource interface {
+	Name() string
+	RandomSentence(ctx context.Context) (string, error)
+}
+
+var sentenceSource SentenceSource = mustCorpusSource()
+
+// SetSentenceSource replaces the source used by GetLongSentence.
+func SetSentenceSource(source SentenceSource) {
+	sentenceSource = source
+}
+
+// CorpusSource serves sentences from the text files embedded in the binary,
+// so it works without network access.
+type CorpusSource struct {
+	sentences []string
+}
+
+// NewCorpusSource loads the embedded corpus. Files are read in name order,
+// one sentence per line; blank lines and lines starting with # are skipped.
+func NewCorpusSource() (*CorpusSource, error) {
+	files, err := fs.Glob(corpusFS, "corpus/*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	var sentences []string
+	for _, name := range files {
+		f, err := corpusFS.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			sentences = append(sentences, line)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+
+	if len(sentences) == 0 {
+		return nil, fmt.Errorf("sentence corpus is empty")
+	}
+	return &CorpusSource{sentences: sentences}, nil
+}
+
+func mustCorpusSource() *CorpusSource {
+	source, err := NewCorpusSource()
+	if err != nil {
+		panic(fmt.Sprintf("failed to load embedded corpus: %v", err))
+	}
+	return source
+}
+
+func (c *CorpusSource) Name() string {
+	return "corpus"
+}
+
+func (c *CorpusSource) RandomSentence(ctx context.Context) (string, error) {
+	return c.sentences[rand.IntN(len(c.sentences))], nil
+}
+
+// HTTPSource fetches random quotes from a thequoteshub-compatible API.
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPSource returns an HTTPSource whose requests give up after timeout.
+func NewHTTPSource(url string, timeout time.Duration) *HTTPSource {
+	return &HTTPSource{
+		URL:    url,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (h *HTTPSource) Name() string {
+	return "http"
+}
+
+func (h *HTTPSource) RandomSentence(ctx context.Context) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %v", err)
+	}
+	response, err := h.Client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to get random sentence: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status: %s", response.Status)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var quote map[string]interface{}
+	err = json.Unmarshal(body, &quote)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal response body: %v", err)
+	}
+
+	quoteText, exists := quote["text"]
+	if !exists {
+		return "", fmt.Errorf("failed to get quote text")
+	}
+
+	quoteString, ok := quoteText.(string)
+	if !ok || strings.TrimSpace(quoteString) == "" {
+		return "", fmt.Errorf("failed to get quote text")
+	}
+
+	return quoteString, nil
+}
+
+// FallbackSource tries each source in order and returns the first sentence
+// one of them produces.
+type FallbackSource struct {
+	sources []SentenceSource
+}
+
+func NewFallbackSource(sources ...SentenceSource) *FallbackSource {
+	return &FallbackSource{sources: sources}
+}
+
+func (f *FallbackSource) Name() string {
+	names := make([]string, len(f.sources))
+	for i, source := range f.sources {
+		names[i] = source.Name()
+	}
+	return "fallback(" + strings.Join(names, ",") + ")"
+}
+
+func (f *FallbackSource) RandomSentence(ctx context.Context) (string, error) {
+	var lastErr error
+	for _, source := range f.sources {
+		sentence, err := source.RandomSentence(ctx)
+		if err == nil && strings.TrimSpace(sentence) != "" {
+			return sentence, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("empty sentence")
+		}
+		log.Printf("sentence source %s failed: %v", source.Name(), err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return "", fmt.Errorf("no sentence sources configured")
+	}
+	return "", fmt.Errorf("all sentence sources failed: %w", lastErr)
+}
@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCorpusSource(t *testing.T) {
+	source, err := NewCorpusSource()
+	if err != nil {
+		t.Fatalf("NewCorpusSource: %v", err)
+	}
+	for range 20 {
+		sentence, err := source.RandomSentence(context.Background())
+		if err != nil || strings.TrimSpace(sentence) == "" || strings.HasPrefix(sentence, "#") {
+			t.Fatalf("RandomSentence = %q, %v, want a corpus line", sentence, err)
+		}
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+		wantErr string
+	}{
+		{"quote", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"text": "Simplicity is prerequisite for reliability.", "author": "Dijkstra"}`))
+		}, "Simplicity is prerequisite for reliability.", ""},
+		{"non-200", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"text": "not a quote"}`, http.StatusTooManyRequests)
+		}, "", "unexpected status"},
+		{"empty body", func(w http.ResponseWriter, r *http.Request) {}, "", "unmarshal"},
+		{"blank text", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"text": "  "}`))
+		}, "", "quote text"},
+		{"no text", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"quote": "misnamed"}`))
+		}, "", "quote text"},
+		{"timeout", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}, "", "failed to get random sentence"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			sentence, err := NewHTTPSource(server.URL, 50*time.Millisecond).RandomSentence(context.Background())
+			if tt.wantErr == "" {
+				if err != nil || sentence != tt.want {
+					t.Errorf("RandomSentence = %q, %v, want %q", sentence, err, tt.want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("RandomSentence = %q, %v, want an error containing %q", sentence, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// stubSource records that it was asked for a sentence and answers with a
+// fixed result.
+type stubSource struct {
+	name     string
+	sentence string
+	err      error
+	calls    *[]string
+}
+
+func (s stubSource) Name() string { return s.name }
+
+func (s stubSource) RandomSentence(ctx context.Context) (string, error) {
+	*s.calls = append(*s.calls, s.name)
+	return s.sentence, s.err
+}
+
+func TestFallbackSource(t *testing.T) {
+	var calls []string
+	failing := stubSource{name: "failing", err: errors.New("down"), calls: &calls}
+	empty := stubSource{name: "empty", sentence: " ", calls: &calls}
+	first := stubSource{name: "first", sentence: "from the first", calls: &calls}
+	second := stubSource{name: "second", sentence: "from the second", calls: &calls}
+
+	source := NewFallbackSource(failing, empty, first, second)
+	if got := source.Name(); got != "fallback(failing,empty,first,second)" {
+		t.Errorf("Name = %q", got)
+	}
+	sentence, err := source.RandomSentence(context.Background())
+	if err != nil || sentence != "from the first" {
+		t.Errorf("RandomSentence = %q, %v, want the first working source's", sentence, err)
+	}
+	if want := []string{"failing", "empty", "first"}; !slices.Equal(calls, want) {
+		t.Errorf("sources asked = %v, want %v", calls, want)
+	}
+
+	calls = nil
+	_, err = NewFallbackSource(failing, empty).RandomSentence(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "all sentence sources failed") {
+		t.Errorf("RandomSentence with every source failing = %v", err)
+	}
+	if _, err := NewFallbackSource().RandomSentence(context.Background()); err == nil {
+		t.Error("RandomSentence without sources succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ServerStats are totals across every ranked daily result, along with how
+// today's challenge is going.
+type ServerStats struct {
+	Players         int     `json:"players"`
+	Runs            int     `json:"runs"`
+	AverageWPM      float64 `json:"average_wpm"`
+	BestWPM         int     `json:"best_wpm"`
+	BestUsername    string  `json:"best_username,omitempty"`
+	BestDateID      string  `json:"best_date_id,omitempty"`
+	LongestStreak   int     `json:"longest_streak"`
+	TodayDateID     string  `json:"today_date_id"`
+	TodayPlayers    int     `json:"today_players"`
+	TodayAverageWPM float64 `json:"today_average_wpm"`
+}
+
+// GetServerStats adds up the player aggregates and today's leaderboard.
+func GetServerStats() (*ServerStats, error) {
+	stats := &ServerStats{TodayDateID: getCurrentDateID()}
+	totalWPM := 0
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(aggregatePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var aggregate PlayerAggregate
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &aggregate)
+			}); err != nil {
+				return err
+			}
+			if aggregate.DaysPlayed == 0 {
+				continue
+			}
+			stats.Players++
+			stats.Runs += aggregate.DaysPlayed
+			totalWPM += aggregate.TotalWPM
+			if aggregate.BestWPM > stats.BestWPM {
+				stats.BestWPM = aggregate.BestWPM
+				stats.BestUsername = aggregate.Username
+				stats.BestDateID = aggregate.BestDateID
+			}
+			stats.LongestStreak = max(stats.LongestStreak, aggregate.LongestStreak)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add up stats: %w", err)
+	}
+	if stats.Runs > 0 {
+		stats.AverageWPM = float64(totalWPM) / float64(stats.Runs)
+	}
+
+	today, err := GetTopEntries(stats.TodayDateID, 0)
+	if err != nil {
+		return nil, err
+	}
+	stats.TodayPlayers = len(today)
+	if len(today) > 0 {
+		todayWPM := 0
+		for _, entry := range today {
+			todayWPM += entry.WPM
+		}
+		stats.TodayAverageWPM = float64(todayWPM) / float64(len(today))
+	}
+	return stats, nil
+}
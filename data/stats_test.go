@@ -0,0 +1,28 @@
+package data
+
+import "testing"
+
+func TestGetServerStats(t *testing.T) {
+	openTestStore(t)
+	seedAggregateFixture(t)
+
+	stats, err := GetServerStats()
+	if err != nil {
+		t.Fatalf("GetServerStats: %v", err)
+	}
+	want := ServerStats{
+		Players:         3,
+		Runs:            15,
+		AverageWPM:      62,
+		BestWPM:         120,
+		BestUsername:    "carol",
+		BestDateID:      daysAgo(t, 40),
+		LongestStreak:   10,
+		TodayDateID:     TodayID(),
+		TodayPlayers:    2,
+		TodayAverageWPM: 80,
+	}
+	if *stats != want {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+}
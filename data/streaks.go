@@ -0,0 +1,68 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Streak counts consecutive challenge days with a ranked result. Days follow
+// the challenge clock, so a streak survives as long as the player plays
+// once between each daily reset.
+type Streak struct {
+	Current int `json:"current"`
+	Longest int `json:"longest"`
+}
+
+// streak returns the player's streak as of the challenge day today. A
+// streak is still current until a whole day passes without a result, so
+// it doesn't drop to zero before today's challenge has been played.
+func (a *PlayerAggregate) streak(today string) Streak {
+	streak := Streak{Longest: a.LongestStreak}
+	if a.LastDateID == "" {
+		return streak
+	}
+	yesterday, err := ShiftDateID(today, -1)
+	if err != nil {
+		return streak
+	}
+	if a.LastDateID == today || a.LastDateID == yesterday {
+		streak.Current = a.StreakDays
+	}
+	return streak
+}
+
+// GetStreak returns a player's current and longest streak.
+func GetStreak(playerID string) (Streak, error) {
+	aggregate, err := GetPlayerAggregate(playerID)
+	if err != nil {
+		return Streak{}, fmt.Errorf("failed to read streak: %w", err)
+	}
+	return aggregate.streak(getCurrentDateID()), nil
+}
+
+// GetStreaks returns the streaks of several players, keyed by player ID.
+// Players who never played are left out.
+func GetStreaks(playerIDs []string) (map[string]Streak, error) {
+	today := getCurrentDateID()
+	streaks := map[string]Streak{}
+	err := db.View(func(txn *badger.Txn) error {
+		for _, playerID := range playerIDs {
+			var aggregate PlayerAggregate
+			err := getTxnValue(txn, aggregateKey(playerID), &aggregate)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			streaks[playerID] = aggregate.streak(today)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read streaks: %w", err)
+	}
+	return streaks, nil
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func useChallengeClock(t *testing.T, timezone string) {
+	t.Helper()
+	clock, err := NewChallengeClock(timezone, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := GetChallengeClock()
+	SetChallengeClock(clock)
+	t.Cleanup(func() { SetChallengeClock(previous) })
+}
+
+func TestStreaks(t *testing.T) {
+	openTestStore(t)
+	seedAggregateFixture(t)
+	// dave played four and three days ago and then yesterday, so his run
+	// ending yesterday is still current
+	for _, days := range []int{4, 3, 1} {
+		seedDay(t, daysAgo(t, days), "text", LeaderBoardEntry{UserID: "d", Username: "dave", WPM: 40})
+	}
+
+	tests := []struct {
+		playerID string
+		want     Streak
+	}{
+		{"a", Streak{Current: 3, Longest: 3}},
+		{"b", Streak{Current: 1, Longest: 1}},
+		{"c", Streak{Current: 0, Longest: 10}},
+		{"d", Streak{Current: 1, Longest: 2}},
+		{"nobody", Streak{}},
+	}
+	for _, tt := range tests {
+		got, err := GetStreak(tt.playerID)
+		if err != nil {
+			t.Fatalf("GetStreak(%s): %v", tt.playerID, err)
+		}
+		if got != tt.want {
+			t.Errorf("GetStreak(%s) = %+v, want %+v", tt.playerID, got, tt.want)
+		}
+	}
+
+	if got, want := boardUsernames(t, BoardStreaks), []string{"alice", "dave", "bob"}; !slices.Equal(got, want) {
+		t.Errorf("streak board = %v, want %v", got, want)
+	}
+
+	board, err := GetLeaderBoard()
+	if err != nil {
+		t.Fatalf("GetLeaderBoard: %v", err)
+	}
+	if got := board.Streaks["a"]; got.Current != 3 {
+		t.Errorf("leaderboard streak for alice = %+v, want current 3", got)
+	}
+}
+
+func TestStreakFollowsChallengeClock(t *testing.T) {
+	openTestStore(t)
+	// the two clocks are a whole day apart, so the eastern clock's
+	// yesterday is the western clock's today
+	useChallengeClock(t, "Etc/GMT+12")
+	westToday := TodayID()
+	seedDay(t, westToday, "text", LeaderBoardEntry{UserID: "a", Username: "alice", WPM: 60})
+	westYesterday := daysAgo(t, 1)
+	seedDay(t, westYesterday, "text", LeaderBoardEntry{UserID: "b", Username: "bob", WPM: 60})
+
+	if streak, _ := GetStreak("b"); streak.Current != 1 {
+		t.Errorf("bob's streak in the west = %+v, want current 1", streak)
+	}
+
+	useChallengeClock(t, "Etc/GMT-12")
+	if got, want := TodayID(), challengeClock.DateID(time.Now()); got != want {
+		t.Fatalf("TodayID = %s, want %s", got, want)
+	}
+	if streak, _ := GetStreak("a"); streak.Current != 1 {
+		t.Errorf("alice's streak in the east = %+v, want current 1", streak)
+	}
+	if streak, _ := GetStreak("b"); streak.Current != 0 || streak.Longest != 1 {
+		t.Errorf("bob's streak in the east = %+v, want broken with longest 1", streak)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"monkeyy/data"
+	"monkeyy/typing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+// ghostModeID is the mode of ghost races, which are never ranked or saved.
+const ghostModeID = "ghost"
+
+var ghostCaretStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#8b5cf6"))
+
+// ghostOption is an entry in the ghost menu: a personal best in a mode, or
+// today's daily #1 when mode is empty.
+type ghostOption struct {
+	label string
+	mode  string
+}
+
+type ghostMenuState struct {
+	options []ghostOption
+	cursor  int
+	loading bool
+	err     string
+}
+
+// ghostState is a recorded run replayed alongside the player's own. The
+// ghost starts moving with the player's first keystroke. gap is filled in
+// once the run is over: how much later than the ghost the player got to the
+// furthest point they both reached, negative when they got there first.
+type ghostState struct {
+	ghost   *data.Ghost
+	session *typing.Session
+	nextKey int
+	gap     time.Duration
+}
+
+type ghostLoadedMsg struct {
+	ghost *data.Ghost
+	err   error
+}
+
+type ghostTickMsg struct {
+	ghost *ghostState
+}
+
+func fetchGhostCmd(playerID string, mode string) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in fetchGhostCmd", "panic", r, "player_id", playerID, "mode", mode)
+			}
+		}()
+
+		var ghost *data.Ghost
+		var err error
+		if mode == "" {
+			ghost, err = data.GetDailyLeaderGhost(data.TodayID())
+		} else {
+			ghost, err = data.GetPersonalBestGhost(playerID, mode)
+		}
+		if err != nil {
+			log.Warn("Could not fetch ghost", "error", err, "player_id", playerID, "mode", mode)
+		}
+		return ghostLoadedMsg{ghost: ghost, err: err}
+	}
+}
+
+// ghostTickCmd moves the ghost more often than the typing test's own tick so
+// its caret keeps to the recorded pace.
+func ghostTickCmd(ghost *ghostState) tea.Cmd {
+	return tea.Tick(time.Millisecond*50, func(t time.Time) tea.Msg {
+		return ghostTickMsg{ghost: ghost}
+	})
+}
+
+// openGhostMenu lists the runs the player can race: every personal best that
+// has its keystrokes recorded and, once they have played it, today's daily
+// #1.
+func openGhostMenu(m model) (model, tea.Cmd) {
+	menu := &ghostMenuState{}
+	for _, mode := range gameModes {
+		if best, ok := m.personalBests[mode.ID]; ok && len(best.Keystrokes) > 0 {
+			menu.options = append(menu.options, ghostOption{
+				label: fmt.Sprintf("%s · your best, %.0f WPM", mode.Name, best.Stats.NetWPM),
+				mode:  mode.ID,
+			})
+		}
+	}
+	if m.hasUserAlreadyDoneDailyChallenge {
+		menu.options = append(menu.options, ghostOption{label: "Today's daily challenge · the #1 run"})
+	}
+	m.ghostMenu = menu
+	return m, nil
+}
+
+func updateGhostMenu(m model, msg tea.Msg) (model, tea.Cmd) {
+	menu := m.ghostMenu
+	switch msg := msg.(type) {
+	case ghostLoadedMsg:
+		menu.loading = false
+		if msg.err != nil {
+			menu.err = "That run can't be raced, pick another one"
+			return m, nil
+		}
+		m.ghostMenu = nil
+		return startGhostRace(m, msg.ghost)
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q":
+			m.ghostMenu = nil
+		case "up", "k":
+			if menu.cursor > 0 {
+				menu.cursor--
+			}
+		case "down", "j":
+			if menu.cursor < len(menu.options)-1 {
+				menu.cursor++
+			}
+		case "enter":
+			if len(menu.options) > 0 && !menu.loading {
+				menu.loading = true
+				menu.err = ""
+				return m, fetchGhostCmd(m.playerID, menu.options[menu.cursor].mode)
+			}
+		}
+	}
+	return m, nil
+}
+
+// startGhostRace starts an unranked run on the ghost's text, timed like the
+// mode the ghost was recorded in.
+func startGhostRace(m model, ghost *data.Ghost) (model, tea.Cmd) {
+	mode := gameMode{ID: ghostModeID, Name: "Ghost race vs " + ghost.Name}
+	for _, recorded := range gameModes {
+		if recorded.ID == ghost.Mode {
+			mode.Duration = recorded.Duration
+		}
+	}
+	m = resetRun(m, mode)
+	m.session = typing.NewSession(ghost.Text, nil)
+	if mode.Duration > 0 {
+		m.session.SetTimeLimit(mode.Duration)
+	}
+	m.ghost = &ghostState{ghost: ghost, session: typing.NewSession(ghost.Text, nil)}
+	return m, ghostTickCmd(m.ghost)
+}
+
+// advance applies every ghost keystroke made within elapsed of its start.
+func (g *ghostState) advance(elapsed time.Duration) {
+	for g.nextKey < len(g.ghost.Keystrokes) {
+		keystroke := g.ghost.Keystrokes[g.nextKey]
+		if time.Duration(keystroke.OffsetMillis)*time.Millisecond > elapsed {
+			break
+		}
+		g.session.Apply(time.Unix(0, 0), keystroke)
+		g.nextKey++
+	}
+}
+
+// advanceGhost keeps the ghost level with the time since the player's first
+// keystroke.
+func advanceGhost(m model) {
+	if m.ghost != nil && m.session.Started() {
+		m.ghost.advance(time.Since(m.session.StartTime()))
+	}
+}
+
+// ghostCaret is where the ghost's caret is drawn, or -1 with no ghost.
+func ghostCaret(m model) int {
+	if m.ghost == nil {
+		return -1
+	}
+	progress, _ := m.ghost.session.Progress()
+	return progress
+}
+
+// finishGhostRace works out the final gap to the ghost. The ghost is played
+// to the end of its run first, as a timed ghost may still have keystrokes
+// left when the player's time runs out.
+func finishGhostRace(m model) {
+	g := m.ghost
+	g.advance(math.MaxInt64)
+	own, _ := m.session.Progress()
+	ghost, _ := g.session.Progress()
+	target := min(own, ghost)
+	g.gap = reachedAt(m.session.Text(), m.session.Keystrokes(), target) - reachedAt(g.ghost.Text, g.ghost.Keystrokes, target)
+}
+
+// reachedAt returns how long into a run the first progress characters of the
+// text had been typed correctly.
+func reachedAt(text string, keystrokes []data.Keystroke, progress int) time.Duration {
+	if progress == 0 {
+		return 0
+	}
+	session := typing.NewSession(text, nil)
+	for _, keystroke := range keystrokes {
+		session.Apply(time.Unix(0, 0), keystroke)
+		if correct, _ := session.Progress(); correct >= progress {
+			return time.Duration(keystroke.OffsetMillis) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// ghostStatus is the live gap to the ghost in characters.
+func ghostStatus(m model) string {
+	own, _ := m.session.Progress()
+	lead := own - ghostCaret(m)
+	switch {
+	case lead > 0:
+		return fmt.Sprintf("👻 %d ahead of %s", lead, m.ghost.ghost.Name)
+	case lead < 0:
+		return fmt.Sprintf("👻 %d behind %s", -lead, m.ghost.ghost.Name)
+	}
+	return "👻 level with " + m.ghost.ghost.Name
+}
+
+// renderGhostResult describes the final gap to the ghost for the run
+// summary.
+func renderGhostResult(m model) []string {
+	g := m.ghost
+	name := g.ghost.Name
+	verdict := fmt.Sprintf("👻 You beat %s by %.2fs", name, -g.gap.Seconds())
+	if g.gap > 0 {
+		verdict = fmt.Sprintf("👻 %s beat you by %.2fs", name, g.gap.Seconds())
+	} else if g.gap == 0 {
+		verdict = "👻 Dead heat with " + name
+	}
+	detailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
+	return []string{
+		m.statsStyle.Render(verdict),
+		detailStyle.Render(fmt.Sprintf("%+.1f WPM (%s: %.1f WPM)", m.runStats.NetWPM-g.ghost.Stats.NetWPM, name, g.ghost.Stats.NetWPM)),
+	}
+}
+
+func renderGhostMenu(m model) string {
+	menu := m.ghostMenu
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Bold(true)
+	rowStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#3b82f6"))
+	detailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444"))
+
+	menuDisplay := []string{titleStyle.Render("👻 Race a ghost"), ""}
+	if len(menu.options) == 0 {
+		menuDisplay = append(menuDisplay, rowStyle.Render("Set a personal best or play today's challenge to have a ghost to race."))
+	}
+	for i, option := range menu.options {
+		line := " " + option.label + " "
+		if i == menu.cursor {
+			menuDisplay = append(menuDisplay, selectedStyle.Render(line))
+		} else {
+			menuDisplay = append(menuDisplay, rowStyle.Render(line))
+		}
+	}
+	if menu.loading {
+		menuDisplay = append(menuDisplay, "", detailStyle.Render("Loading..."))
+	}
+	if menu.err != "" {
+		menuDisplay = append(menuDisplay, "", errorStyle.Render(menu.err))
+	}
+	menuDisplay = append(menuDisplay, "", detailStyle.Render("↑ ↓: select | enter: race | esc: back"))
+	return lipgloss.JoinVertical(lipgloss.Left, menuDisplay...)
+}
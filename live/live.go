@@ -0,0 +1,134 @@
+// Package live keeps track of the typing sessions running on this server so
+// other players can watch them as they happen. Like race rooms it only knows
+// about sessions connected to the same server process.
+package live
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"monkeyy/typing"
+)
+
+// Run is a session's run as spectators see it. Typed is compared with Text
+// the same way the typist's own screen does it. DailyText marks texts from
+// the daily challenge, which players shouldn't see before they've played it.
+type Run struct {
+	Mode      string
+	DailyText bool
+	Text      string
+	Typed     string
+	Stats     typing.Stats
+	Finished  bool
+}
+
+// Typist is a session that is typing right now.
+type Typist struct {
+	ID       string
+	Username string
+	Run      Run
+}
+
+// Registry holds every connected session. Sessions publish their run as
+// they type and spectators read the latest one whenever they like, so
+// watching a session costs the typist no more than a short lock.
+type Registry struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+// Session is one connection's entry in the registry. Its methods do nothing
+// on a nil session, so models created outside an SSH session can call them
+// freely.
+type Session struct {
+	registry *Registry
+	id       string
+	username string
+	run      Run
+	typing   bool
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{sessions: map[string]*Session{}}
+}
+
+// Register adds the session id to the registry, replacing any earlier entry
+// with the same id.
+func (r *Registry) Register(id string) *Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &Session{registry: r, id: id}
+	r.sessions[id] = s
+	return s
+}
+
+// Unregister removes the session id, for when its connection closes.
+func (r *Registry) Unregister(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sessions, id)
+}
+
+// Typing returns the sessions that are typing right now, ordered by
+// username.
+func (r *Registry) Typing() []Typist {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	typists := []Typist{}
+	for _, s := range r.sessions {
+		if s.typing {
+			typists = append(typists, Typist{ID: s.id, Username: s.username, Run: s.run})
+		}
+	}
+	slices.SortFunc(typists, func(a, b Typist) int {
+		if c := strings.Compare(a.Username, b.Username); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return typists
+}
+
+// Watch returns the run the session id published last. It reports false
+// once the session has stopped typing or disconnected.
+func (r *Registry) Watch(id string) (Run, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := r.sessions[id]
+	if s == nil || !s.typing {
+		return Run{}, false
+	}
+	return s.run, true
+}
+
+// Publish replaces the session's run with where the typist has got to.
+func (s *Session) Publish(username string, run Run) {
+	if s == nil {
+		return
+	}
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+
+	s.username = username
+	s.run = run
+	s.typing = true
+}
+
+// Idle takes the session off the list of typists, for when it leaves the
+// typing screen.
+func (s *Session) Idle() {
+	if s == nil {
+		return
+	}
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+
+	s.typing = false
+	s.run = Run{}
+}
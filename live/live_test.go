@@ -0,0 +1,38 @@
+package live
+
+import "testing"
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	bob := registry.Register("s2")
+	alice := registry.Register("s1")
+	registry.Register("s3") // connected but not typing
+
+	bob.Publish("bob", Run{Text: "go fast", Typed: "go"})
+	alice.Publish("alice", Run{Text: "go fast", Typed: "g"})
+	typists := registry.Typing()
+	if len(typists) != 2 || typists[0].Username != "alice" || typists[1].Username != "bob" {
+		t.Fatalf("typists = %+v, want alice and bob", typists)
+	}
+
+	bob.Publish("bob", Run{Text: "go fast", Typed: "go f"})
+	if run, ok := registry.Watch("s2"); !ok || run.Typed != "go f" {
+		t.Errorf("Watch(s2) = %+v, %v", run, ok)
+	}
+
+	bob.Idle()
+	if _, ok := registry.Watch("s2"); ok {
+		t.Error("watching a session that stopped typing")
+	}
+	registry.Unregister("s1")
+	if _, ok := registry.Watch("s1"); ok {
+		t.Error("watching a disconnected session")
+	}
+	if typists := registry.Typing(); len(typists) != 0 {
+		t.Errorf("typists = %+v, want none", typists)
+	}
+
+	var detached *Session
+	detached.Publish("nobody", Run{})
+	detached.Idle()
+}
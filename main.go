@@ -3,9 +3,15 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"monkeyy/api"
+	"monkeyy/config"
 	"monkeyy/data"
+	"monkeyy/live"
+	"monkeyy/typing"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -25,81 +31,137 @@ import (
 	recovermw "github.com/charmbracelet/wish/recover"
 	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 
-const (
-   host = "0.0.0.0" // Bind to all interfaces for production
-   port = "22"
-)
+// serverConfig is the configuration loaded at startup. Sessions read it but
+// never modify it.
+var serverConfig = config.Default()
 
 
 func main() {
+   cfg, err := config.Load(os.Args[1:])
+   if err != nil {
+       if errors.Is(err, flag.ErrHelp) {
+           os.Exit(0)
+       }
+       fmt.Fprintln(os.Stderr, err)
+       os.Exit(2)
+   }
+   serverConfig = *cfg
+
+   level, _ := log.ParseLevel(cfg.LogLevel)
+   log.SetLevel(level)
+
+   clock, _ := cfg.ChallengeClock()
+   data.SetChallengeClock(clock)
+   data.SetWordCount(cfg.WordCount)
+
+   // The embedded corpus is always available; a quotes API can be put in
+   // front of it, falling back to the corpus when the API misbehaves.
+   if cfg.QuotesAPIURL != "" {
+       corpus, err := data.NewCorpusSource()
+       if err != nil {
+           log.Fatal("Could not load sentence corpus", "error", err)
+       }
+       data.SetSentenceSource(data.NewFallbackSource(data.NewHTTPSource(cfg.QuotesAPIURL, 5*time.Second), corpus))
+   }
+
+   // Daily challenges are derived from the date and this secret, so every
+   // instance sharing it serves the same text.
+   if cfg.ChallengeSeed == "" {
+       log.Warn("No challenge seed configured, daily challenges are predictable")
+   }
+   data.SetChallengeSeed(cfg.ChallengeSeed)
+
    // Initialize database
    fmt.Println("Initializing database...")
-   data.InitInMemoryStore()
+   data.InitInMemoryStore(cfg.DBPath)
 
 
    // Initialize cron scheduler for daily sentence generation
-   c := initCronScheduler()
+   c := initCronScheduler(clock)
    c.Start()
    defer c.Stop()
 
 
-   hostKeyPath := os.Getenv("SSH_HOST_KEY_PATH")
-   if hostKeyPath == "" {
-       hostKeyPath = ".ssh/id_ed25519"
+   options := []ssh.Option{wish.WithAddress(cfg.ListenAddress)}
+   for _, hostKeyPath := range cfg.HostKeyPaths {
+       options = append(options, wish.WithHostKeyPath(hostKeyPath))
    }
 
 
-   s, err := wish.NewServer(
-       wish.WithAddress(net.JoinHostPort(host, port)),
-       wish.WithHostKeyPath(hostKeyPath),
+   s, err := wish.NewServer(append(options,
+       // Any public key is accepted and becomes the player's identity.
+       // Clients without a key get keyboard-interactive as an anonymous
+       // fallback; plain "none" auth can't be offered alongside public keys
+       // because clients probe with "none" first and would never send a key.
+       wish.WithPublicKeyAuth(func(ctx ssh.Context, key ssh.PublicKey) bool { return true }),
+       wish.WithKeyboardInteractiveAuth(func(ctx ssh.Context, challenger gossh.KeyboardInteractiveChallenge) bool { return true }),
        wish.WithMiddleware(
         recovermw.Middleware(
             activeterm.Middleware(),
             bubbletea.Middleware(teaHandler),
+            // sessions without a terminal run a command instead of the game
+            commandMiddleware(),
             logging.Middleware(),
         ),
        ),
-   )
+   )...)
    if err != nil {
-       log.Error("Could not start server", "error", err)
+       log.Fatal("Could not start server", "error", err)
    }
 
 
-   done := make(chan os.Signal, 1)
+   // room for a signal and a failure from each server, so no sender blocks
+   done := make(chan os.Signal, 3)
    signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-   log.Info("Starting SSH server", "host", host, "port", port)
+   log.Info("Starting SSH server", "address", cfg.ListenAddress)
    go func() {
-       if err = s.ListenAndServe(); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
+       if err := s.ListenAndServe(); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
            log.Error("Could not start server", "error", err)
            done <- nil
        }
    }()
 
+   var httpServer *http.Server
+   if cfg.HTTPAddress != "" {
+       httpServer = &http.Server{Addr: cfg.HTTPAddress, Handler: api.NewHandler(), ReadHeaderTimeout: 10 * time.Second}
+       log.Info("Starting HTTP API", "address", cfg.HTTPAddress)
+       go func() {
+           if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+               log.Error("Could not start HTTP API", "error", err)
+               done <- nil
+           }
+       }()
+   }
+
 
    <-done
-   data.Shutdown() // Save data before shutting down
-   log.Info("Stopping SSH server")
    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
    defer func() { cancel() }()
+   // Finish the SSH sessions and API requests in flight before the
+   // database closes under them
+   log.Info("Stopping SSH server")
    if err := s.Shutdown(ctx); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
        log.Error("Could not stop server", "error", err)
    }
+   if httpServer != nil {
+       log.Info("Stopping HTTP API")
+       if err := httpServer.Shutdown(ctx); err != nil {
+           log.Error("Could not stop HTTP API", "error", err)
+       }
+   }
+   data.Shutdown() // Save data before shutting down
 }
 
 
 // initCronScheduler sets up the daily sentence generation cron job
-func initCronScheduler() *cron.Cron {
-	location, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		log.Fatal("Could not load location for cron", "error", err)
-	}
-
-	c := cron.New(cron.WithLocation(location))
+func initCronScheduler(clock data.ChallengeClock) *cron.Cron {
+	c := cron.New(cron.WithLocation(clock.Location))
 
-	c.AddFunc("0 0 * * *", func() {
+	c.AddFunc(clock.CronSpec(), func() {
 		defer func() {
 			if r := recover(); r != nil {
 				log.Error("Panic in cron job", "panic", r)
@@ -107,14 +169,7 @@ func initCronScheduler() *cron.Cron {
 		}()
 
 		log.Info("Cron job started - generating daily sentence")
-		sentence, err := data.GetLongSentence()
-		if err != nil {
-			log.Error("Error getting long sentence", "error", err)
-			return
-		}
-
-		log.Debug("Generated sentence", "length", len(sentence))
-		err = data.InsertSentence(sentence)
+		sentence, err := data.GenerateTodaysSentence()
 		if err != nil {
 			log.Error("Error inserting sentence", "error", err)
 			return
@@ -165,47 +220,68 @@ func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
    statsStyle := renderer.NewStyle().Foreground(lipgloss.Color("#8b5cf6")).Bold(true)                                // Purple stats
 
 
-   var userIdentifier string
-//    if pubKey := s.PublicKey(); pubKey != nil {
-//        log.Debug("Public key auth detected")
-//        hash := sha256.Sum256(pubKey.Marshal())
-//        userIdentifier = fmt.Sprintf("%x", hash)
-//        userIdDisplay := userIdentifier
-//        if len(userIdentifier) > 16 {
-//            userIdDisplay = userIdentifier[:16] + "..."
-//        }
-//        log.Debug("User identifier generated", "user_id", userIdDisplay)
-//        // Using public key auth
-//    } else {
-       log.Debug("No public key found, using username and IP as identifier")
-       remoteAddr := s.RemoteAddr().String()
-       ip, _, err := net.SplitHostPort(remoteAddr)
-       if err != nil {
-           // If parsing fails, use the whole remote address string as a fallback for the ip part
-            log.Warn("Could not parse IP from remote address", "remote_addr", remoteAddr, "error", err)
-            ip = remoteAddr
-       }
-       user := s.User()
-       if user == "" {
-           user = "anonymous"
-       }
-       userIdentifier = fmt.Sprintf("%s-%s", user, ip)
-       log.Debug("User identifier generated", "user_id", userIdentifier)
+   identity, anonymous := sessionIdentity(s)
+   playerID, err := data.ResolvePlayerID(identity, anonymous)
+   if err != nil {
+       // Without a player record the session could never claim a username
+       // or save a run, so end it rather than let the player type for nothing
+       log.Error("Could not resolve player", "error", err, "anonymous", anonymous)
+       wish.Fatalln(s, "Sorry, something went wrong on our side. Please try again later.")
+       return nil, nil
+   }
+   log.Debug("Player resolved", "player_id", playerID, "anonymous", anonymous)
 
+   log.Debug("Creating new model with styles")
 
+   m := NewModelWithStyles(correctStyle, incorrectStyle, normalStyle, currentStyle, statsStyle, playerID)
+   m.isAdmin = isAdminIdentity(identity, anonymous)
+   log.Debug("Model created successfully")
 
-//    userIdentifier += randomIdGenerator()
+   // `ssh <host> race [code]` goes straight to the race screen
+   if command := s.Command(); len(command) > 0 && command[0] == "race" {
+       m.raceRequested = true
+       if len(command) > 1 {
+           m.raceCode = command[1]
+       }
+   }
 
-   // User public key hashed and ready
-   log.Debug("Creating new model with styles")
+   m.liveSession = liveSessions.Register(m.sessionID)
 
-   m := NewModelWithStyles(correctStyle, incorrectStyle, normalStyle, currentStyle, statsStyle, userIdentifier)
-   log.Debug("Model created successfully")
+   // a dropped connection must not leave the player stuck in a race room,
+   // or listed as typing
+   go func() {
+       <-s.Context().Done()
+       raceHub.LeaveSession(m.sessionID)
+       liveSessions.Unregister(m.sessionID)
+   }()
 
    return m, []tea.ProgramOption{tea.WithAltScreen()}
 }
 
 
+// sessionIdentity returns the identity a session is known by. Public key
+// logins are identified by the key's SHA256 fingerprint; keyless logins fall
+// back to an anonymous username and IP pair.
+func sessionIdentity(s ssh.Session) (string, bool) {
+   if pubKey := s.PublicKey(); pubKey != nil {
+       return "pubkey:" + gossh.FingerprintSHA256(pubKey), false
+   }
+
+   remoteAddr := s.RemoteAddr().String()
+   ip, _, err := net.SplitHostPort(remoteAddr)
+   if err != nil {
+       // If parsing fails, use the whole remote address string as a fallback for the ip part
+       log.Warn("Could not parse IP from remote address", "remote_addr", remoteAddr, "error", err)
+       ip = remoteAddr
+   }
+   user := s.User()
+   if user == "" {
+       user = "anonymous"
+   }
+   return fmt.Sprintf("anon:%s-%s", user, ip), true
+}
+
+
 func randomIdGenerator() string {
    return uuid.New().String()
 }
@@ -215,9 +291,13 @@ var MOCK_USER_ID = randomIdGenerator()
 
 
 type leaderboardEntry struct {
-   UserID   string `json:"UserID"`
-   Username string `json:"Username"`
-   WPM      int    `json:"WPM"`
+   UserID   string  `json:"UserID"`
+   Username string  `json:"Username"`
+   WPM      int     `json:"WPM"`
+   Accuracy float64     `json:"Accuracy"`
+   Flagged  bool        `json:"Flagged"`
+   Streak   data.Streak `json:"Streak"`
+   Rank     int         `json:"Rank"`
 }
 
 
@@ -227,12 +307,20 @@ type userDailyChallengeStatusReceivedMsg struct {
 
 
 type leaderboardReceivedMsg struct {
+   requested          string
+   offset             int
    DateID             string             `json:"DateID"`
+   Sentence           string             `json:"Sentence"`
    LeaderboardEntries []leaderboardEntry `json:"LeaderboardEntries"`
+   Total              int                `json:"Total"`
+   LeaderID           string             `json:"LeaderID"`
+   Own                *leaderboardEntry  `json:"Own"`
+   OwnRow             int                `json:"OwnRow"`
 }
 
 
 type randomSentenceReceivedMsg struct {
+   dateID   string
    sentence string
 }
 
@@ -243,6 +331,17 @@ type sentenceSubmittedMsg struct {
 }
 
 
+type playerReceivedMsg struct {
+   player *data.Player
+}
+
+
+type usernameClaimedMsg struct {
+   player *data.Player
+   err    error
+}
+
+
 func fetchUserDailyChallengeStatusCmd(userId string) tea.Cmd {
    return func() tea.Msg {
        defer func() {
@@ -269,41 +368,115 @@ func fetchUserDailyChallengeStatusCmd(userId string) tea.Cmd {
 }
 
 
-func fetchTodaysLeaderBoardCmd() tea.Cmd {
+func fetchPlayerCmd(playerID string) tea.Cmd {
+   return func() tea.Msg {
+       defer func() {
+           if r := recover(); r != nil {
+               log.Error("Panic in fetchPlayerCmd", "panic", r, "player_id", playerID)
+           }
+       }()
+
+       log.Debug("Fetching player", "player_id", playerID)
+       player, err := data.TouchPlayer(playerID)
+       if err != nil {
+           log.Error("Error fetching player", "error", err, "player_id", playerID)
+           return playerReceivedMsg{player: nil}
+       }
+
+       log.Debug("Player fetched", "player_id", playerID, "username", player.Username)
+       return playerReceivedMsg{player: player}
+   }
+}
+
+
+func claimUsernameCmd(playerID string, username string) tea.Cmd {
+   return func() tea.Msg {
+       defer func() {
+           if r := recover(); r != nil {
+               log.Error("Panic in claimUsernameCmd", "panic", r, "player_id", playerID, "username", username)
+           }
+       }()
+
+       log.Debug("Claiming username", "player_id", playerID, "username", username)
+       player, err := data.ClaimUsername(playerID, username)
+       if err != nil {
+           log.Warn("Could not claim username", "error", err, "player_id", playerID, "username", username)
+           return usernameClaimedMsg{err: err}
+       }
+
+       log.Info("Username claimed", "player_id", playerID, "username", player.Username)
+       return usernameClaimedMsg{player: player}
+   }
+}
+
+
+// fetchLeaderBoardCmd loads a page of the leaderboard of a day, or today's
+// when dateID is empty, along with where playerID stands on it.
+func fetchLeaderBoardCmd(dateID string, playerID string, offset int, limit int) tea.Cmd {
    return func() tea.Msg {
        defer func() {
            if r := recover(); r != nil {
-               log.Error("Panic in fetchTodaysLeaderBoardCmd", "panic", r)
+               log.Error("Panic in fetchLeaderBoardCmd", "panic", r, "date_id", dateID)
            }
        }()
 
-       log.Debug("Fetching today's leaderboard")
-       leaderboard, err := data.GetLeaderBoard()
+       day := dateID
+       if day == "" {
+           day = data.TodayID()
+       }
+       log.Debug("Fetching leaderboard page", "date_id", day, "offset", offset)
+       page, err := data.GetLeaderBoardPage(day, playerID, offset, limit)
        if err != nil {
-           log.Error("Error fetching leaderboard", "error", err)
-           return leaderboardReceivedMsg{DateID: "", LeaderboardEntries: []leaderboardEntry{}}
+           log.Error("Error fetching leaderboard", "error", err, "date_id", day)
+           return leaderboardReceivedMsg{requested: dateID, offset: offset, DateID: day, LeaderboardEntries: []leaderboardEntry{}, OwnRow: -1}
        }
 
-       log.Debug("Leaderboard fetched", "date_id", leaderboard.DateID, "entries_count", len(leaderboard.LeaderboardEntries))
+       log.Debug("Leaderboard fetched", "date_id", page.DateID, "entries_count", len(page.Entries), "total", page.Total)
 
        // Convert LeaderboardEntry to local leaderboardEntry type
-       entries := make([]leaderboardEntry, len(leaderboard.LeaderboardEntries))
-       for i, entry := range leaderboard.LeaderboardEntries {
-           entries[i] = leaderboardEntry{
+       toLocal := func(entry data.LeaderBoardEntry) leaderboardEntry {
+           return leaderboardEntry{
                UserID:   entry.UserID,
                Username: entry.Username,
                WPM:      entry.WPM,
+               Accuracy: entry.Stats.Accuracy,
+               Flagged:  entry.Status == data.RunStatusFlagged,
+               Streak:   page.Streaks[entry.UserID],
+               Rank:     entry.Rank,
            }
        }
+       entries := make([]leaderboardEntry, len(page.Entries))
+       for i, entry := range page.Entries {
+           entries[i] = toLocal(entry)
+       }
+       var own *leaderboardEntry
+       if page.Own != nil {
+           entry := toLocal(*page.Own)
+           own = &entry
+       }
 
        return leaderboardReceivedMsg{
-           DateID:             leaderboard.DateID,
+           requested:          dateID,
+           offset:             offset,
+           DateID:             page.DateID,
+           Sentence:           page.Sentence,
            LeaderboardEntries: entries,
+           Total:              page.Total,
+           LeaderID:           page.LeaderID,
+           Own:                own,
+           OwnRow:             page.OwnRow,
        }
    }
 }
 
 
+// fetchLeaderBoardPage loads the page of the daily leaderboard the player
+// is looking at.
+func fetchLeaderBoardPage(m model) tea.Cmd {
+   return fetchLeaderBoardCmd(m.viewDateID, m.playerID, m.currentPage*m.entriesPerPage, m.entriesPerPage)
+}
+
+
 func getRandomSentenceCmd() tea.Cmd {
    return func() tea.Msg {
        defer func() {
@@ -312,24 +485,27 @@ func getRandomSentenceCmd() tea.Cmd {
            }
        }()
 
-       log.Debug("Fetching today's sentence")
-       sentence, err := data.GetTodaysSentence()
+       dateID := data.TodayID()
+       log.Debug("Fetching today's sentence", "date_id", dateID)
+       sentence, err := data.GetDailySentence(dateID)
        if err != nil {
            log.Error("Error fetching random sentence", "error", err)
-           return randomSentenceReceivedMsg{sentence: ""}
+           return randomSentenceReceivedMsg{dateID: dateID, sentence: ""}
        }
 
        log.Debug("Sentence fetched", "length", len(sentence))
-       return randomSentenceReceivedMsg{sentence: sentence}
+       return randomSentenceReceivedMsg{dateID: dateID, sentence: sentence}
    }
 }
 
 
-func submitSentenceCmd(userId string, username string, wpm int) tea.Cmd {
+// submitSentenceCmd submits a daily run for dateID, the day its text
+// belongs to, even when the run finishes shortly after the reset.
+func submitSentenceCmd(dateID string, startedAt time.Time, userId string, username string, stats data.RunStats, keystrokes []data.Keystroke) tea.Cmd {
    return func() tea.Msg {
        defer func() {
            if r := recover(); r != nil {
-               log.Error("Panic in submitSentenceCmd", "panic", r, "user_id", userId, "username", username, "wpm", wpm)
+               log.Error("Panic in submitSentenceCmd", "panic", r, "user_id", userId, "username", username, "wpm", stats.NetWPM)
            }
        }()
 
@@ -338,14 +514,14 @@ func submitSentenceCmd(userId string, username string, wpm int) tea.Cmd {
            userIdDisplay = userId[:16] + "..."
        }
 
-       log.Debug("Submitting sentence", "user_id", userIdDisplay, "username", username, "wpm", wpm)
-       err := data.SubmitSentence(context.Background(), userId, username, wpm)
+       log.Debug("Submitting sentence", "date_id", dateID, "user_id", userIdDisplay, "username", username, "wpm", stats.NetWPM, "accuracy", stats.Accuracy)
+       err := data.SubmitSentence(context.Background(), dateID, startedAt, userId, username, stats, keystrokes)
        if err != nil {
-           log.Error("Error submitting sentence", "error", err, "user_id", userIdDisplay, "username", username, "wpm", wpm)
+           log.Error("Error submitting sentence", "error", err, "user_id", userIdDisplay, "username", username, "wpm", stats.NetWPM)
            return sentenceSubmittedMsg{success: false, message: err.Error()}
        }
 
-       log.Info("Sentence submitted successfully", "user_id", userIdDisplay, "username", username, "wpm", wpm)
+       log.Info("Sentence submitted successfully", "user_id", userIdDisplay, "username", username, "wpm", stats.NetWPM)
        return sentenceSubmittedMsg{success: true, message: "Sentence submitted successfully"}
    }
 }
@@ -373,7 +549,9 @@ func leaderboardPollCmd() tea.Cmd {
 
 func (m model) Init() tea.Cmd {
    return tea.Batch(
-       fetchUserDailyChallengeStatusCmd(m.userPublicKey),
+       fetchPlayerCmd(m.playerID),
+       fetchUserDailyChallengeStatusCmd(m.playerID),
+       fetchPersonalBestsCmd(m.playerID),
        getRandomSentenceCmd(),
        tickCmd(), // Start the tick timer
    )
@@ -387,22 +565,93 @@ type model struct {
 	userSetUsername                  bool
 	username                         string
 	usernameInput                    textinput.Model
+	usernameError                    string
+	welcomeMessage                   string
 
 
 	// leaderboard related fields
 	dateID             string
-	LeaderboardEntries []leaderboardEntry
+	viewDateID         string
+	leaderboardText    string
+	calendar           *calendarState
+	boardTab           int
+	aggregateEntries   []data.AggregateEntry // the page on screen
+	aggregateOffset    int
+	aggregateTotal     int
+	aggregateOwn       *data.AggregateEntry
+	aggregateOwnRow    int
+	orderByDaysPlayed  bool
+	LeaderboardEntries []leaderboardEntry // the page on screen
+	boardOffset        int
+	boardTotal         int
+	leaderID           string
+	ownEntry           *leaderboardEntry
+	ownBoardRow        int
 	currentPage        int
 	entriesPerPage     int
 	countdown          string
+	polling            bool
+
+
+	// mode selection related fields
+	mode          *gameMode
+	modeCursor        int
+	personalBests     map[string]data.PersonalBest
+	dailyText         string
+	dailyDateID       string // the day dailyText belongs to
+	runDateID         string // the day of the daily run in progress
+	backToLeaderboard bool
 
 
 	// typing test related fields
-	textToType         string
-	textUserTyped      string
-	WPM                int
-	startTime          time.Time
-	didUserStartTyping bool
+	session *typing.Session
+	WPM     int
+	runDone bool
+
+
+	// post-run summary related fields
+	showingSummary bool
+	runStats        data.RunStats
+	submitError     string
+	newBest         bool
+	practiceHistory []data.PracticeResult
+	streak          *data.Streak
+
+
+	// profile screen related fields
+	profile *profileState
+
+
+	// ghost race related fields
+	ghostMenu *ghostMenuState
+	ghost     *ghostState
+
+
+	// spectate related fields; liveSession is how spectators see this
+	// session's own runs
+	spectate    *spectateState
+	liveSession *live.Session
+
+
+	// race related fields; raceRequested opens the race screen (joining
+	// raceCode if set) once the player has a username
+	race          *raceState
+	raceRequested bool
+	raceCode      string
+
+
+	// replay viewer related fields
+	replay           *replayState
+	replayError      string
+	replayGeneration int
+
+
+	// admin review related fields
+	isAdmin       bool
+	showingAdmin  bool
+	flaggedRuns   []data.FlaggedRun
+	flaggedCursor int
+	adminStatus   string
 
 
 	// viewport size
@@ -415,21 +664,107 @@ type model struct {
 	normalStyle    lipgloss.Style
 	currentStyle   lipgloss.Style
 	statsStyle     lipgloss.Style
-	userPublicKey  string
+	playerID       string
+	sessionID      string
 }
 
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
    defer func() {
        if r := recover(); r != nil {
-           userIdDisplay := m.userPublicKey
-           if len(m.userPublicKey) > 16 {
-               userIdDisplay = m.userPublicKey[:16] + "..."
+           userIdDisplay := m.playerID
+           if len(m.playerID) > 16 {
+               userIdDisplay = m.playerID[:16] + "..."
            }
            log.Error("Panic in model Update", "panic", r, "msg_type", fmt.Sprintf("%T", msg), "user_id", userIdDisplay)
        }
    }()
 
+   if m.replay != nil {
+       switch msg := msg.(type) {
+       case tea.KeyMsg:
+           if msg.String() == "ctrl+c" {
+               return m, tea.Quit
+           }
+           return updateReplay(m, msg)
+       case replayTickMsg:
+           return updateReplay(m, msg)
+       }
+   }
+
+   if m.calendar != nil {
+       switch msg := msg.(type) {
+       case tea.KeyMsg:
+           if msg.String() == "ctrl+c" {
+               return m, tea.Quit
+           }
+           return updateCalendar(m, msg)
+       case challengeDatesMsg:
+           return updateCalendar(m, msg)
+       }
+   }
+
+   if m.race != nil {
+       switch msg := msg.(type) {
+       case tea.KeyMsg:
+           if msg.String() == "ctrl+c" {
+               m, _ = leaveRace(m)
+               return m, tea.Quit
+           }
+           return updateRace(m, msg)
+       case raceUpdateMsg, raceClosedMsg:
+           return updateRace(m, msg)
+       }
+   }
+
+   if m.profile != nil {
+       switch msg := msg.(type) {
+       case tea.KeyMsg:
+           if msg.String() == "ctrl+c" {
+               return m, tea.Quit
+           }
+           return updateProfile(m, msg)
+       case profileLoadedMsg:
+           return updateProfile(m, msg)
+       }
+   }
+
+   if m.ghostMenu != nil {
+       switch msg := msg.(type) {
+       case tea.KeyMsg:
+           if msg.String() == "ctrl+c" {
+               return m, tea.Quit
+           }
+           return updateGhostMenu(m, msg)
+       case ghostLoadedMsg:
+           return updateGhostMenu(m, msg)
+       }
+   }
+
+   if m.spectate != nil {
+       switch msg := msg.(type) {
+       case tea.KeyMsg:
+           if msg.String() == "ctrl+c" {
+               return m, tea.Quit
+           }
+           return updateSpectate(m, msg)
+       case spectateTickMsg:
+           return updateSpectate(m, msg)
+       }
+   }
+
+   if m.showingAdmin {
+       switch msg := msg.(type) {
+       case tea.KeyMsg:
+           if msg.String() == "ctrl+c" {
+               return m, tea.Quit
+           }
+           return updateAdmin(m, msg)
+       case flaggedRunsLoadedMsg, runReviewedMsg:
+           return updateAdmin(m, msg)
+       }
+   }
+
    switch msg := msg.(type) {
 
    case tea.WindowSizeMsg:
@@ -441,15 +776,99 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
    case randomSentenceReceivedMsg:
        log.Debug("Random sentence received", "length", len(msg.sentence))
-       m.textToType = msg.sentence
+       m.dailyText = msg.sentence
+       m.dailyDateID = msg.dateID
+       if m.mode != nil && m.mode.ranked() && !m.session.Started() {
+           m.session = typing.NewSession(msg.sentence, nil)
+           m.runDateID = msg.dateID
+       }
+       return m, nil
+
+   case modeTextReceivedMsg:
+       if m.mode == nil || m.mode.ID != msg.modeID || m.session.Started() {
+           return m, nil
+       }
+       if msg.err != nil {
+           m.submitError = "Could not load a text, press esc and try again"
+           return m, nil
+       }
+       m.session = typing.NewSession(msg.text, nil)
+       if m.mode.Duration > 0 {
+           m.session.SetTimeLimit(m.mode.Duration)
+       }
+       return m, nil
+
+   case personalBestsLoadedMsg:
+       if msg.bests != nil {
+           m.personalBests = msg.bests
+       }
+       return m, nil
+
+   case personalBestSavedMsg:
+       if msg.err == nil {
+           if m.personalBests == nil {
+               m.personalBests = map[string]data.PersonalBest{}
+           }
+           m.personalBests[msg.best.Mode] = msg.best
+           m.newBest = msg.isNew
+       }
        return m, nil
 
+   case streakLoadedMsg:
+       if msg.err == nil {
+           m.streak = &msg.streak
+       }
+       return m, nil
+
+   case practiceRecordedMsg:
+       if msg.err == nil {
+           m.practiceHistory = msg.history
+       }
+       return m, nil
+
+   case playerReceivedMsg:
+       if msg.player != nil && msg.player.Username != "" && !m.userSetUsername {
+           log.Debug("Returning player", "username", msg.player.Username)
+           m.username = msg.player.Username
+           m.userSetUsername = true
+           m.welcomeMessage = fmt.Sprintf("welcome back, %s", msg.player.Username)
+           m.usernameInput.Blur()
+           return openRequestedRace(m)
+       }
+       return m, nil
+
+   case usernameClaimedMsg:
+       if msg.err != nil {
+           if errors.Is(msg.err, data.ErrUsernameTaken) {
+               m.usernameError = "That username is already taken, please pick another one"
+           } else {
+               m.usernameError = "Could not save your username, please try again"
+           }
+           return m, nil
+       }
+       m.username = msg.player.Username
+       m.userSetUsername = true
+       m.usernameError = ""
+       m.usernameInput.Blur()
+       return openRequestedRace(m)
+
+   case replayLoadedMsg:
+       if msg.err != nil {
+           m.replayError = "No replay is available for this run"
+           return m, nil
+       }
+       m.replayError = ""
+       m.replayGeneration++
+       m.replay = newReplayState(msg.replay, m.replayGeneration)
+       return m, replayTickCmd(m.replayGeneration)
+
    case sentenceSubmittedMsg:
        log.Debug("Sentence submission result", "success", msg.success, "message", msg.message)
        if msg.success {
-           return m, fetchTodaysLeaderBoardCmd()
+           return m, tea.Batch(fetchLeaderBoardPage(m), fetchStreakCmd(m.playerID))
        } else {
            log.Warn("Sentence submission failed", "message", msg.message)
+           m.submitError = msg.message
        }
        return m, nil
 
@@ -458,62 +877,91 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
    case userDailyChallengeStatusReceivedMsg:
        log.Debug("User daily challenge status received", "already_done", msg.userAlreadyDidDailyChallenge)
        m.hasUserAlreadyDoneDailyChallenge = msg.userAlreadyDidDailyChallenge
-       return m, fetchTodaysLeaderBoardCmd()
+       return m, fetchLeaderBoardPage(m)
 
    case leaderboardReceivedMsg:
        log.Debug("Leaderboard received", "date_id", msg.DateID, "entries_count", len(msg.LeaderboardEntries))
-       m.dateID = msg.DateID
-       m.LeaderboardEntries = msg.LeaderboardEntries
+       if msg.requested == m.viewDateID && msg.offset == m.currentPage*m.entriesPerPage {
+           m.dateID = msg.DateID
+           m.leaderboardText = msg.Sentence
+           m.LeaderboardEntries = msg.LeaderboardEntries
+           m.boardOffset = msg.offset
+           m.boardTotal = msg.Total
+           m.leaderID = msg.LeaderID
+           m.ownEntry = msg.Own
+           m.ownBoardRow = msg.OwnRow
+       }
        // start polling for leaderboard updates if we're on the leaderboard screen
-       if m.hasUserAlreadyDoneDailyChallenge {
+       if m.onLeaderboard() && !m.polling {
+           m.polling = true
            return m, leaderboardPollCmd()
        }
        return m, nil
 
 
    case leaderboardPollMsg:
-       duration := timeUntilNextMidnight()
+       duration := timeUntilNextReset()
        m.countdown = formatDuration(duration)
-       // continue polling if we're still on the leaderboard screen
-       if m.hasUserAlreadyDoneDailyChallenge {
-           return m, fetchTodaysLeaderBoardCmd()
+       m.polling = false
+       // continue polling if we're still on the leaderboard screen; the
+       // aggregate boards only reload when asked to
+       if m.onLeaderboard() {
+           return m, fetchLeaderBoardPage(m)
        }
        return m, nil
 
+   case aggregateBoardMsg:
+       return updateAggregateBoard(m, msg), nil
+
 
    // typing test related updates
 
 
-   case tickMsg:
-       if m.didUserStartTyping {
-           totalCorrectCharactersTyped := 0
-           for i, char := range m.textUserTyped {
-               if i < len(m.textToType) && char == []rune(m.textToType)[i] {
-                   totalCorrectCharactersTyped++
-               }
-           }
-           // check for division by zero
-           if time.Since(m.startTime).Minutes() > 0 {
-               m.WPM = int(float64(totalCorrectCharactersTyped) / 5.0 / time.Since(m.startTime).Minutes())
-           }
+   case ghostTickMsg:
+       if msg.ghost != m.ghost || m.runDone {
+           return m, nil
+       }
+       advanceGhost(m)
+       return m, ghostTickCmd(m.ghost)
 
-           if didUserFinishTyping(m) && !m.hasUserAlreadyDoneDailyChallenge {
-               // User finished typing, submitting sentence
-               m.hasUserAlreadyDoneDailyChallenge = true
-               return m, submitSentenceCmd(m.userPublicKey, m.username, m.WPM)
+   case tickMsg:
+       if m.mode != nil && !m.runDone && m.session.Started() {
+           m.WPM = int(m.session.Stats().NetWPM)
+
+           if m.session.Finished() {
+               // User finished typing, recording the run
+               m, cmd := finishRun(m)
+               publishRun(m)
+               return m, tea.Batch(cmd, tickCmd())
            }
        }
-       if !m.hasUserAlreadyDoneDailyChallenge {
-           return m, tickCmd()
-       }
-       return m, nil
+       publishRun(m)
+       return m, tickCmd()
    case tea.KeyMsg:
       if msg.String() == "ctrl+c" {
           return m, tea.Quit
       }
 
-      if m.hasUserAlreadyDoneDailyChallenge {
-          totalPages := (len(m.LeaderboardEntries) + m.entriesPerPage - 1) / m.entriesPerPage
+      if m.showingSummary {
+          switch msg.String() {
+          case "enter":
+              m.showingSummary = false
+              if m.mode.ranked() {
+                  return m, fetchLeaderBoardPage(m)
+              }
+              if m.ghost != nil {
+                  return startGhostRace(m, m.ghost.ghost)
+              }
+              return startMode(m, *m.mode)
+          case "esc":
+              m.showingSummary = false
+              return leaveMode(m)
+          }
+          return m, nil
+      }
+
+      if m.onLeaderboard() {
+          totalPages := (m.boardLen() + m.entriesPerPage - 1) / m.entriesPerPage
           if totalPages == 0 {
               totalPages = 1
           }
@@ -521,21 +969,88 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
           switch msg.String() {
           case "left", "h":
               if m.currentPage > 0 {
-                  m.currentPage--
+                  return showPage(m, m.currentPage-1)
               }
               return m, nil
           case "right", "l":
               if m.currentPage < totalPages-1 {
-                  m.currentPage++
+                  return showPage(m, m.currentPage+1)
               }
               return m, nil
           case "home", "g":
-              m.currentPage = 0
-              return m, nil
+              return showPage(m, 0)
           case "end", "G":
-              m.currentPage = totalPages - 1
+              return showPage(m, totalPages-1)
+          case "f":
+              if row := m.ownRow(); row >= 0 {
+                  return showPage(m, row/m.entriesPerPage)
+              }
+              return m, nil
+          case "tab":
+              return switchBoardTab(m, m.boardTab+1)
+          case "shift+tab":
+              return switchBoardTab(m, m.boardTab-1)
+          case "o":
+              if boardTabs[m.boardTab].Board == data.BoardAllTime {
+                  m.orderByDaysPlayed = !m.orderByDaysPlayed
+                  return switchBoardTab(m, m.boardTab)
+              }
+              return m, nil
+          }
+
+          // replays and date navigation only apply to the daily tab,
+          // where r watches the top run instead of refreshing
+          if m.aggregateBoard() != "" {
+              switch msg.String() {
+              case "r":
+                  return m, fetchAggregatePage(m)
+              case "[", "]", "t", "c":
+                  return m, nil
+              }
+          }
+
+          switch msg.String() {
+          case "r":
+              if m.leaderID != "" {
+                  return m, fetchReplayCmd(m.dateID, m.leaderID)
+              }
               return m, nil
+          case "A":
+              if m.isAdmin {
+                  m.showingAdmin = true
+                  m.adminStatus = ""
+                  return m, fetchFlaggedRunsCmd()
+              }
+              return m, nil
+          case "[", "]":
+              dateID := m.dateID
+              if dateID == "" {
+                  dateID = data.TodayID()
+              }
+              days := -1
+              if msg.String() == "]" {
+                  days = 1
+              }
+              if shifted, err := data.ShiftDateID(dateID, days); err == nil {
+                  return showLeaderboardDate(m, shifted)
+              }
+              return m, nil
+          case "t":
+              return showLeaderboardDate(m, "")
+          case "c":
+              m.calendar = newCalendarState(m.dateID)
+              return m, fetchChallengeDatesCmd()
+          case "p":
+              return startPractice(m)
+          case "u":
+              return openProfile(m)
+          case "w":
+              return openSpectate(m)
+          case "esc", "m":
+              m.backToLeaderboard = false
+              return leaveMode(m)
           }
+          return m, nil
       }
 
       if !m.userSetUsername {
@@ -551,9 +1066,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                       }
                   }
                   if valid {
-                      m.username = username
-                      m.userSetUsername = true
-                      m.usernameInput.Blur()
+                      return m, claimUsernameCmd(m.playerID, username)
                   }
               }
               return m, nil
@@ -563,44 +1076,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
           return m, cmd
       }
 
+      if m.mode == nil {
+          return updateModeSelect(m, msg)
+      }
+
+      // the daily challenge can only be left before its first key
+      if msg.String() == "esc" && (!m.mode.ranked() || !m.session.Started()) {
+          return leaveMode(m)
+      }
+
 
        if msg.String() == "backspace" {
-           m.didUserStartTyping = true
-           if len(m.textUserTyped) == 0 {
-               return m, nil
-           }
-           if len(m.textUserTyped) > 0 {
-               m.textUserTyped = m.textUserTyped[:len(m.textUserTyped)-1]
-               // Check if string is not empty before accessing the last character
-               if len(m.textUserTyped) > 0 && m.textUserTyped[len(m.textUserTyped)-1] == '\n' {
-                   m.textUserTyped = m.textUserTyped[:len(m.textUserTyped)-1]
-               }
-           }
+           m.session.Backspace()
+           publishRun(m)
            return m, nil
        }
 
 
-       if len(msg.String()) == 1 {
-           m.didUserStartTyping = true
-           r := rune(msg.String()[0])
-           if unicode.IsLetter(r) || unicode.IsPunct(r) || unicode.IsSpace(r) || unicode.IsNumber(r) {
-               if len(m.textUserTyped) == 0 {
-                   m.startTime = time.Now()
-               }
-               if len(m.textUserTyped) < len(m.textToType) {
-                   nextChar := []rune(m.textToType)[len([]rune(m.textUserTyped))]
-                   if nextChar == '\n' {
-                       m.textUserTyped += "\n"
-                       if len(m.textUserTyped) < len(m.textToType) {
-                           m.textUserTyped += msg.String()
-                       }
-                   } else {
-                       m.textUserTyped += msg.String()
-                   }
-               }
+       if keys := typedKeys(msg); len(keys) > 0 {
+           for _, key := range keys {
+               m.session.Type(key, time.Now())
            }
-
-
+           if m.mode.Duration > 0 && m.session.Started() && m.session.Left() < endlessRefillChars {
+               m.session.Extend(" " + data.RandomWords(endlessWordBatch))
+           }
+           advanceGhost(m)
+           publishRun(m)
            return m, nil
        }
 
@@ -613,20 +1114,47 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 
 func (m model) View() string {
-   if m.hasUserAlreadyDoneDailyChallenge {
-       // TODO: show leaderboard
-       return renderLeaderboard(m)
-   } else {
-       if m.userSetUsername {
-           return renderTypingTest(m)
-       } else {
-           return renderUsernamePrompt(m)
+   if m.replay != nil {
+       return renderReplay(m)
+   }
+   if m.showingAdmin {
+       return renderAdmin(m)
+   }
+   if m.race != nil {
+       return renderRace(m)
+   }
+   if m.profile != nil {
+       return renderProfile(m)
+   }
+   if m.ghostMenu != nil {
+       return renderGhostMenu(m)
+   }
+   if m.spectate != nil {
+       return renderSpectate(m)
+   }
+   if m.showingSummary {
+       return renderRunSummary(m)
+   }
+   if !m.userSetUsername {
+       return renderUsernamePrompt(m)
+   }
+   if m.mode == nil {
+       return renderModeSelect(m)
+   }
+   if m.onLeaderboard() {
+       if m.calendar != nil {
+           return renderCalendar(m)
        }
-
-
+       return renderLeaderboard(m)
    }
+   return renderTypingTest(m)
+}
 
 
+// onLeaderboard reports whether the daily leaderboard is on screen, which is
+// where the daily challenge leads once it has been played.
+func (m model) onLeaderboard() bool {
+   return m.mode != nil && m.mode.ranked() && m.hasUserAlreadyDoneDailyChallenge
 }
 
 
@@ -643,34 +1171,36 @@ func createUsernameInput() textinput.Model {
 func NewModel() model {
    // Default styles for non-SSH usage (fallback)
    return model{
-       textToType:     "Loading sentence...",
+       session:        typing.NewSession("Loading sentence...", nil),
        WPM:            0,
-       startTime:      time.Now(),
        usernameInput:  createUsernameInput(),
+       dailyDateID:    data.TodayID(),
        correctStyle:   lipgloss.NewStyle().Foreground(lipgloss.Color("#10b981")),
        incorrectStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444")).Background(lipgloss.Color("#7f1d1d")),
        normalStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("#6b7280")),
        currentStyle:   lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#3b82f6")),
        statsStyle:     lipgloss.NewStyle().Foreground(lipgloss.Color("#8b5cf6")).Bold(true),
+       sessionID:      randomIdGenerator(),
    }
 }
 
 
-func NewModelWithStyles(correctStyle, incorrectStyle, normalStyle, currentStyle, statsStyle lipgloss.Style, userPublicKey string) model {
+func NewModelWithStyles(correctStyle, incorrectStyle, normalStyle, currentStyle, statsStyle lipgloss.Style, playerID string) model {
 	return model{
-		textToType:     "Loading sentence...",
+		session:        typing.NewSession("Loading sentence...", nil),
 		WPM:            0,
-		startTime:      time.Now(),
 		usernameInput:  createUsernameInput(),
+		dailyDateID:    data.TodayID(),
 		currentPage:    0,
-		entriesPerPage: 10,
+		entriesPerPage: serverConfig.EntriesPerPage,
 		countdown:      "00:00:00",
 		correctStyle:   correctStyle,
 		incorrectStyle: incorrectStyle,
 		normalStyle:    normalStyle,
 		currentStyle:   currentStyle,
 		statsStyle:     statsStyle,
-		userPublicKey:  userPublicKey,
+		playerID:       playerID,
+		sessionID:      randomIdGenerator(),
 	}
 }
 
@@ -678,8 +1208,89 @@ func NewModelWithStyles(correctStyle, incorrectStyle, normalStyle, currentStyle,
 // helper methods
 
 
-func didUserFinishTyping(m model) bool {
-   return len(m.textUserTyped) == len([]rune(m.textToType)) && m.textUserTyped == m.textToType
+// typedKeys returns the characters a key press types, one per grapheme
+// cluster. Terminals can deliver several characters in one event when typing
+// fast; pasted text is ignored.
+func typedKeys(msg tea.KeyMsg) []string {
+	if msg.Type == tea.KeySpace {
+		return []string{" "}
+	}
+	if msg.Type != tea.KeyRunes || msg.Paste {
+		return nil
+	}
+	keys := []string{}
+	for _, key := range typing.Graphemes(string(msg.Runes)) {
+		if typing.IsTypeable(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+
+func renderRunSummary(m model) string {
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Bold(true)
+	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Width(20)
+	valueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
+	controlsStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+
+	stats := m.runStats
+	rows := []struct {
+		label string
+		value string
+	}{
+		{"Net WPM", fmt.Sprintf("%.1f", stats.NetWPM)},
+		{"Raw WPM", fmt.Sprintf("%.1f", stats.RawWPM)},
+		{"Accuracy", fmt.Sprintf("%.1f%%", stats.Accuracy)},
+		{"Keystrokes", fmt.Sprintf("%d", stats.Keystrokes)},
+		{"Corrected errors", fmt.Sprintf("%d", stats.CorrectedErrors)},
+		{"Uncorrected errors", fmt.Sprintf("%d", stats.UncorrectedErrors)},
+		{"Time", fmt.Sprintf("%.2fs", float64(stats.ElapsedMillis)/1000)},
+	}
+
+	title := "🏁 Run complete"
+	if m.mode != nil {
+		title += " - " + m.mode.Name
+	}
+	summaryDisplay := []string{titleStyle.Render(title), ""}
+	for _, row := range rows {
+		summaryDisplay = append(summaryDisplay, lipgloss.JoinHorizontal(lipgloss.Left,
+			labelStyle.Render(row.label),
+			valueStyle.Render(row.value),
+		))
+	}
+	if m.submitError != "" {
+		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444"))
+		summaryDisplay = append(summaryDisplay, "", errorStyle.Render("Your run was not accepted: "+m.submitError))
+	}
+	if m.ghost != nil {
+		summaryDisplay = append(summaryDisplay, "")
+		summaryDisplay = append(summaryDisplay, renderGhostResult(m)...)
+		summaryDisplay = append(summaryDisplay, controlsStyle.Render("Ghost races don't count towards personal bests"))
+		summaryDisplay = append(summaryDisplay, "", controlsStyle.Render("enter: race again | esc: "+leaveModeHint(m)))
+	} else if m.mode != nil && !m.mode.ranked() {
+		if m.newBest {
+			summaryDisplay = append(summaryDisplay, "", m.statsStyle.Render("⭐ New personal best!"))
+		} else if best, ok := m.personalBests[m.mode.ID]; ok {
+			summaryDisplay = append(summaryDisplay, "", controlsStyle.Render(fmt.Sprintf("Personal best: %.1f WPM", best.Stats.NetWPM)))
+		}
+		if len(m.practiceHistory) > 0 {
+			recent := make([]string, len(m.practiceHistory))
+			for i, result := range m.practiceHistory {
+				recent[i] = fmt.Sprintf("%.0f", result.Stats.NetWPM)
+			}
+			summaryDisplay = append(summaryDisplay, controlsStyle.Render("Recent practice runs: "+strings.Join(recent, " · ")+" WPM"))
+		}
+		summaryDisplay = append(summaryDisplay, controlsStyle.Render("Practice runs don't count towards the daily leaderboard"))
+		summaryDisplay = append(summaryDisplay, "", controlsStyle.Render("enter: go again | esc: "+leaveModeHint(m)))
+	} else {
+		if m.streak != nil && m.streak.Current > 0 {
+			summaryDisplay = append(summaryDisplay, "", m.statsStyle.Render(fmt.Sprintf("🔥 %d-day streak! Longest: %d days", m.streak.Current, m.streak.Longest)))
+		}
+		summaryDisplay = append(summaryDisplay, "", controlsStyle.Render("Press Enter to see the leaderboard"))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, summaryDisplay...)
 }
 
 
@@ -687,18 +1298,45 @@ func renderLeaderboard(m model) string {
 	// add date id as title first
 	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Bold(true)
 	dateIDTitle := titleStyle.Render("🏆 Daily Leaderboard - " + m.dateID)
-	leaderboardDisplay := []string{dateIDTitle, ""}
+	daily := m.aggregateBoard() == ""
+	if !daily {
+		title := boardTabs[m.boardTab].Title
+		if m.aggregateBoard() == data.BoardMostPlayed {
+			title = "All-time - most days played"
+		}
+		dateIDTitle = titleStyle.Render("🏆 " + title)
+	}
+	leaderboardDisplay := []string{renderBoardTabs(m), "", dateIDTitle, ""}
+
+	// past days show the text that was typed that day
+	if daily && m.viewDateID != "" {
+		textStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Italic(true)
+		if m.width > 4 {
+			textStyle = textStyle.Width(m.width - 4)
+		}
+		text := m.leaderboardText
+		if text == "" {
+			text = "No challenge was played this day"
+		}
+		leaderboardDisplay = append(leaderboardDisplay, textStyle.Render(text), "")
+	}
 
 	availableHeight := m.height - 2
 	if availableHeight < 5 {
 		availableHeight = 5
 	}
 
-	if len(m.LeaderboardEntries) == 0 {
+	if m.boardLen() == 0 {
 		emptyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Italic(true)
-		leaderboardDisplay = append(leaderboardDisplay, emptyStyle.Render("   No entries yet today!"))
+		if !daily {
+			leaderboardDisplay = append(leaderboardDisplay, emptyStyle.Render("   Nobody qualifies yet"))
+		} else if m.viewDateID != "" {
+			leaderboardDisplay = append(leaderboardDisplay, emptyStyle.Render("   No entries for this day"))
+		} else {
+			leaderboardDisplay = append(leaderboardDisplay, emptyStyle.Render("   No entries yet today!"))
+		}
 	} else {
-		totalPages := (len(m.LeaderboardEntries) + m.entriesPerPage - 1) / m.entriesPerPage
+		totalPages := (m.boardLen() + m.entriesPerPage - 1) / m.entriesPerPage
 		if totalPages == 0 {
 			totalPages = 1
 		}
@@ -710,33 +1348,34 @@ func renderLeaderboard(m model) string {
 			m.currentPage = 0
 		}
 
-		startIdx := m.currentPage * m.entriesPerPage
-		endIdx := startIdx + m.entriesPerPage
-		if endIdx > len(m.LeaderboardEntries) {
-			endIdx = len(m.LeaderboardEntries)
-		}
-		for i, entry := range m.LeaderboardEntries[startIdx:endIdx] {
-			actualIndex := startIdx + i
-			username := entry.Username
-
+		// until a newly chosen daily page arrives only what is loaded of it
+		// is shown
+		loadedStart, loadedEnd := m.loadedRows()
+		startIdx := max(m.currentPage*m.entriesPerPage, loadedStart)
+		endIdx := min(m.currentPage*m.entriesPerPage+m.entriesPerPage, loadedEnd)
+		ownRow := m.ownRow()
+		for actualIndex := startIdx; actualIndex < endIdx; actualIndex++ {
 			var prefix string
 			var entryStyle lipgloss.Style
-			switch actualIndex {
-			case 0:
+			switch rank := m.boardRank(actualIndex); rank {
+			case 1:
 				prefix = "🥇"
 				entryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#ffd700")).Bold(true)
-			case 1:
+			case 2:
 				prefix = "🥈"
 				entryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#c0c0c0")).Bold(true)
-			case 2:
+			case 3:
 				prefix = "🥉"
 				entryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#cd7f32")).Bold(true)
 			default:
-				prefix = fmt.Sprintf("%2d.", actualIndex+1)
+				prefix = fmt.Sprintf("%2d.", rank)
 				entryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
 			}
+			if actualIndex == ownRow {
+				entryStyle = entryStyle.Background(lipgloss.Color("#3b82f6"))
+			}
 
-			entryText := fmt.Sprintf(" %s %s: %d WPM", prefix, username, entry.WPM)
+			entryText := fmt.Sprintf(" %s %s", prefix, boardEntryText(m, actualIndex))
 			leaderboardDisplay = append(leaderboardDisplay, entryStyle.Render(entryText))
 		}
 	}
@@ -744,14 +1383,27 @@ func renderLeaderboard(m model) string {
 	paginationStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#666666"))
 	controlsStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
 
-	totalPages := (len(m.LeaderboardEntries) + m.entriesPerPage - 1) / m.entriesPerPage
+	totalPages := (m.boardLen() + m.entriesPerPage - 1) / m.entriesPerPage
 	if totalPages == 0 {
 		totalPages = 1
 	}
 
-	pageInfo := fmt.Sprintf("Page %d of %d (%d total entries)", m.currentPage+1, totalPages, len(m.LeaderboardEntries))
-	controls := "← → or h l: navigate pages | g: first page | G: last page"
+	pageInfo := fmt.Sprintf("Page %d of %d (%d total entries)", m.currentPage+1, totalPages, m.boardLen())
+	controls := "← → or h l: navigate pages | g: first page | G: last page | f: find yourself | p: practice | u: your profile | w: watch | m: modes"
+	if m.isAdmin {
+		controls += " | A: review flagged runs"
+	}
+	dateControls := "tab: switch board | [ ]: previous/next day | c: calendar | r: watch the top run"
 	countdown := fmt.Sprintf("Next challenge in %s", m.countdown)
+	if m.viewDateID != "" {
+		dateControls += " | t: back to today"
+	}
+	if !daily {
+		dateControls = "tab: switch board | r: refresh"
+		if boardTabs[m.boardTab].Board == data.BoardAllTime {
+			dateControls += " | o: order by best day / days played"
+		}
+	}
 
 	spacerWidth := m.width - lipgloss.Width(paginationStyle.Render(pageInfo)) - lipgloss.Width(paginationStyle.Render(countdown))
 	if spacerWidth < 0 {
@@ -766,7 +1418,7 @@ func renderLeaderboard(m model) string {
 	)
 
 	contentLines := len(leaderboardDisplay)
-	emptyLinesNeeded := availableHeight - contentLines - 3
+	emptyLinesNeeded := availableHeight - contentLines - 5
 
 	if emptyLinesNeeded > 0 {
 		for i := 0; i < emptyLinesNeeded; i++ {
@@ -774,26 +1426,22 @@ func renderLeaderboard(m model) string {
 		}
 	}
 
-	leaderboardDisplay = append(leaderboardDisplay, "")
+	if m.replayError != "" {
+		leaderboardDisplay = append(leaderboardDisplay, controlsStyle.Render(m.replayError))
+	} else {
+		leaderboardDisplay = append(leaderboardDisplay, "")
+	}
+	leaderboardDisplay = append(leaderboardDisplay, m.statsStyle.Render(ownRankText(m)))
 	leaderboardDisplay = append(leaderboardDisplay, bottomLine)
 	leaderboardDisplay = append(leaderboardDisplay, controlsStyle.Render(controls))
+	leaderboardDisplay = append(leaderboardDisplay, controlsStyle.Render(dateControls))
 
 	return lipgloss.JoinVertical(lipgloss.Left, leaderboardDisplay...)
 }
 
-func timeUntilNextMidnight() time.Duration {
-	location, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		// Fallback to UTC on error
-		now := time.Now().UTC()
-		tomorrow := now.Add(24 * time.Hour)
-		midnight := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, time.UTC)
-		return midnight.Sub(now)
-	}
-	now := time.Now().In(location)
-	tomorrow := now.Add(24 * time.Hour)
-	midnight := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, location)
-	return midnight.Sub(now)
+func timeUntilNextReset() time.Duration {
+	now := time.Now()
+	return data.GetChallengeClock().NextReset(now).Sub(now)
 }
 
 func formatDuration(d time.Duration) string {
@@ -807,60 +1455,28 @@ func formatDuration(d time.Duration) string {
 }
 
 func renderTypingTest(m model) string {
-   typedText := m.textUserTyped
-   needToTypeTextRunes := []rune(m.textToType)
-
-
-   var textBuilder strings.Builder
-
-
-   typedLength := len([]rune(typedText))
-   foundError := false
-   highlightNextAsCurrent := false
-
-
-   // Process each character in the text to type
-   for i, char := range needToTypeTextRunes {
-       if char == '\n' {
-           if i == typedLength {
-               highlightNextAsCurrent = true
-           }
-           textBuilder.WriteRune('\n')
-           continue
-       }
-
-
-       if highlightNextAsCurrent {
-           textBuilder.WriteString(m.currentStyle.Render(string(char)))
-           highlightNextAsCurrent = false
-           continue
+   header := m.welcomeMessage
+   status := fmt.Sprintf("WPM: %d", m.WPM)
+   if m.mode != nil && !m.mode.ranked() {
+       header = m.mode.Name
+       if m.mode.Duration > 0 {
+           status += fmt.Sprintf("  ·  %ds left", int(m.session.TimeLeft().Round(time.Second).Seconds()))
        }
-
-
-       if i < typedLength {
-           typedChar := []rune(typedText)[i]
-           if foundError {
-               if i >= 0 && i < len(needToTypeTextRunes) {
-                   textBuilder.WriteString(m.incorrectStyle.Render(string(needToTypeTextRunes[i])))
-               }
-           } else if typedChar != char {
-               foundError = true
-               if i >= 0 && i < len(needToTypeTextRunes) {
-                   textBuilder.WriteString(m.incorrectStyle.Render(string(needToTypeTextRunes[i])))
-               }
-           } else {
-               textBuilder.WriteString(m.correctStyle.Render(string(typedChar)))
-           }
-       } else if i == typedLength {
-           textBuilder.WriteString(m.currentStyle.Render(string(char)))
-       } else {
-           textBuilder.WriteString(m.normalStyle.Render(string(char)))
+       if m.ghost != nil {
+           status += "  ·  " + ghostStatus(m)
        }
+       status += "  ·  esc: " + leaveModeHint(m)
+   } else if m.mode != nil && !m.session.Started() {
+       status += "  ·  esc: " + leaveModeHint(m)
    }
+   return renderTypingScreen(m, header, renderSessionText(m, m.session, ghostCaret(m)), status)
+}
 
 
-   textDisplay := textBuilder.String()
-   wpmDisplay := m.statsStyle.Render(fmt.Sprintf("WPM: %d", m.WPM))
+// renderTypingScreen lays out a typing test: a header, the text and a status
+// line under it.
+func renderTypingScreen(m model, header string, textDisplay string, status string) string {
+   wpmDisplay := m.statsStyle.Render(status)
 
 
    if m.width > 0 {
@@ -871,7 +1487,7 @@ func renderTypingTest(m model) string {
 
 
    return lipgloss.JoinVertical(lipgloss.Left,
-       "",
+       m.statsStyle.Render(header),
        textDisplay,
        "",
        "",
@@ -880,6 +1496,39 @@ func renderTypingTest(m model) string {
 }
 
 
+// renderSessionText colours a session's text by what has been typed so far.
+// A ghost's caret is drawn at ghostCaret unless it is -1; the player's own
+// caret is drawn over it.
+func renderSessionText(m model, session *typing.Session, ghostCaret int) string {
+	chars, states := session.Chars()
+
+	var textBuilder strings.Builder
+	for i, char := range chars {
+		if char == "\n" {
+			textBuilder.WriteString("\n")
+			continue
+		}
+
+		if i == ghostCaret && states[i] != typing.CharCurrent {
+			textBuilder.WriteString(ghostCaretStyle.Render(char))
+			continue
+		}
+
+		switch states[i] {
+		case typing.CharCorrect:
+			textBuilder.WriteString(m.correctStyle.Render(char))
+		case typing.CharIncorrect:
+			textBuilder.WriteString(m.incorrectStyle.Render(char))
+		case typing.CharCurrent:
+			textBuilder.WriteString(m.currentStyle.Render(char))
+		default:
+			textBuilder.WriteString(m.normalStyle.Render(char))
+		}
+	}
+	return textBuilder.String()
+}
+
+
 func renderUsernamePrompt(m model) string {
 	titleStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#ffffff")).
@@ -904,6 +1553,7 @@ func renderUsernamePrompt(m model) string {
 	rules := []string{
 		"📋 Rules:",
 		"• Username: 6-20 characters (letters, numbers, _ and - only)",
+		"• Usernames are first come, first served and stay yours",
 		"• Type the sentence with 100% accuracy and as fast as possible",
 		"• You can only play once per day",
 		"• Your score will appear on the daily leaderboard",
@@ -926,6 +1576,10 @@ func renderUsernamePrompt(m model) string {
 	}
 
 	inputPrompt := instructionStyle.Render("Press Enter to confirm")
+	if m.usernameError != "" {
+		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444"))
+		inputPrompt = lipgloss.JoinVertical(lipgloss.Center, errorStyle.Render(m.usernameError), inputPrompt)
+	}
 	inputStyle := lipgloss.NewStyle().Align(lipgloss.Center)
 	centeredInput := inputStyle.Render(m.usernameInput.View())
 
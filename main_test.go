@@ -0,0 +1,692 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"monkeyy/data"
+	"monkeyy/race"
+	"monkeyy/typing"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+)
+
+func sendKey(m model, msg tea.KeyMsg) model {
+	next, _ := m.Update(msg)
+	return next.(model)
+}
+
+func TestTypedKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  tea.KeyMsg
+		want []string
+	}{
+		{"letter", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")}, []string{"a"}},
+		{"space", tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}, []string{" "}},
+		{"accented letter", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("é")}, []string{"é"}},
+		{"combining mark", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("́")}, []string{"́"}},
+		{"emoji", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("👩‍💻")}, []string{"👩‍💻"}},
+		{"several keys in one event", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("aé🙂")}, []string{"a", "é", "🙂"}},
+		{"paste is ignored", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("hello"), Paste: true}, nil},
+		{"control key", tea.KeyMsg{Type: tea.KeyEnter}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := typedKeys(tt.msg); !slices.Equal(got, tt.want) {
+				t.Errorf("typedKeys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypingScreenKeys(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	m.mode = &gameModes[0]
+	m.session = typing.NewSession("olé 🎉", nil)
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("olé")})
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("🎉")})
+	if !m.session.Finished() {
+		t.Fatalf("typed %q, want finished", m.session.Typed())
+	}
+
+	for _, want := range []string{"olé ", "olé", "ol"} {
+		m = sendKey(m, tea.KeyMsg{Type: tea.KeyBackspace})
+		if got := m.session.Typed(); got != want {
+			t.Errorf("after backspace typed %q, want %q", got, want)
+		}
+	}
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("é 🎉"), Paste: true})
+	if got := m.session.Typed(); got != "ol" {
+		t.Errorf("paste typed %q, want it ignored", got)
+	}
+	if got := len(m.session.Keystrokes()); got != 8 {
+		t.Errorf("keystrokes = %d, want 8", got)
+	}
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode == nil {
+		t.Error("esc left the daily challenge after it started")
+	}
+}
+
+func TestLeaveDailyBeforeStarting(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	m.dailyText = "olé 🎉"
+	m, _ = startMode(m, gameModes[0])
+	if !strings.Contains(m.View(), "esc: choose another mode") {
+		t.Errorf("the daily challenge does not say how to leave it:\n%s", m.View())
+	}
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != nil {
+		t.Error("esc did not leave the daily challenge before its first key")
+	}
+}
+
+func modeByID(t *testing.T, id string) int {
+	t.Helper()
+	for i, mode := range gameModes {
+		if mode.ID == id {
+			return i
+		}
+	}
+	t.Fatalf("no mode %q", id)
+	return 0
+}
+
+func TestWordCountModeRun(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	m.modeCursor = modeByID(t, "words-10")
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.mode == nil || m.mode.ID != "words-10" {
+		t.Fatalf("mode = %v, want words-10", m.mode)
+	}
+	next, _ := m.Update(modeTextReceivedMsg{modeID: "words-10", text: "go fast"})
+	m = next.(model)
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("go")})
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("fast")})
+	next, cmd := m.Update(tickMsg{})
+	m = next.(model)
+	if !m.showingSummary || !m.runDone || cmd == nil {
+		t.Fatalf("run not finished after typing the whole text (summary %v, done %v)", m.showingSummary, m.runDone)
+	}
+	if m.hasUserAlreadyDoneDailyChallenge {
+		t.Error("word-count run used up the daily challenge")
+	}
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != nil || m.showingSummary {
+		t.Errorf("esc from the summary should return to mode selection")
+	}
+}
+
+func TestTimedModeExtendsText(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	m, _ = startMode(m, gameModes[modeByID(t, "time-15")])
+	next, _ := m.Update(modeTextReceivedMsg{modeID: "time-15", text: "a b"})
+	m = next.(model)
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	if m.session.Left() < endlessRefillChars {
+		t.Errorf("timed mode left %d characters to type, want more words added", m.session.Left())
+	}
+	if m.session.TimeLeft() > 15*time.Second || m.session.Finished() {
+		t.Errorf("timed mode session should be running with a 15s limit")
+	}
+}
+
+func TestPracticeFromLeaderboard(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	m.entriesPerPage = 10
+	m.hasUserAlreadyDoneDailyChallenge = true
+	m, _ = startMode(m, gameModes[0])
+	if !m.onLeaderboard() {
+		t.Fatal("daily mode should show the leaderboard once played")
+	}
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if m.onLeaderboard() || m.mode == nil || !m.mode.Practice {
+		t.Fatalf("p on the leaderboard should start practice, mode = %v", m.mode)
+	}
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if !m.onLeaderboard() {
+		t.Error("esc from practice should return to the leaderboard")
+	}
+}
+
+func TestLeaderboardDateNavigation(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	m.entriesPerPage = 10
+	m.hasUserAlreadyDoneDailyChallenge = true
+	m, _ = startMode(m, gameModes[0])
+	today := data.TodayID()
+	yesterday, _ := data.ShiftDateID(today, -1)
+	m.dateID = today
+	m.currentPage = 2
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("[")})
+	if m.viewDateID != yesterday || m.currentPage != 0 {
+		t.Errorf("[ shows %q page %d, want %q page 0", m.viewDateID, m.currentPage, yesterday)
+	}
+
+	next, _ := m.Update(leaderboardReceivedMsg{requested: "", DateID: today})
+	m = next.(model)
+	if m.dateID != today {
+		t.Errorf("a stale board for today replaced the one being viewed")
+	}
+	next, _ = m.Update(leaderboardReceivedMsg{requested: yesterday, DateID: yesterday, Sentence: "old text"})
+	m = next.(model)
+	if m.dateID != yesterday || m.leaderboardText != "old text" {
+		t.Errorf("board for %s not shown: date %q, text %q", yesterday, m.dateID, m.leaderboardText)
+	}
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("]")})
+	if m.viewDateID != "" {
+		t.Errorf("] back to today should follow today's board, got %q", m.viewDateID)
+	}
+}
+
+func TestBoardTabs(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	m.entriesPerPage = 10
+	m.hasUserAlreadyDoneDailyChallenge = true
+	m, _ = startMode(m, gameModes[0])
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyShiftTab})
+	if m.aggregateBoard() != data.BoardStreaks {
+		t.Fatalf("shift+tab from daily shows %q, want %q", m.aggregateBoard(), data.BoardStreaks)
+	}
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyShiftTab})
+	if m.aggregateBoard() != data.BoardAllTime {
+		t.Fatalf("shift+tab from streaks shows %q, want %q", m.aggregateBoard(), data.BoardAllTime)
+	}
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+	if m.aggregateBoard() != data.BoardMostPlayed {
+		t.Errorf("o on all-time shows %q, want %q", m.aggregateBoard(), data.BoardMostPlayed)
+	}
+
+	next, _ := m.Update(aggregateBoardMsg{board: data.BoardAllTime, page: &data.AggregatePage{Entries: []data.AggregateEntry{{Username: "stale"}}, Total: 1, OwnRow: -1}})
+	m = next.(model)
+	if m.boardLen() != 0 {
+		t.Errorf("entries for another board were applied")
+	}
+	next, _ = m.Update(aggregateBoardMsg{board: data.BoardMostPlayed, offset: 10, page: &data.AggregatePage{Entries: []data.AggregateEntry{{Username: "stale"}}, Total: 11, OwnRow: -1}})
+	m = next.(model)
+	if m.boardLen() != 0 {
+		t.Errorf("entries for another page were applied")
+	}
+	own := data.AggregateEntry{UserID: m.playerID, Username: "you", DaysPlayed: 3}
+	next, _ = m.Update(aggregateBoardMsg{board: data.BoardMostPlayed, page: &data.AggregatePage{Entries: []data.AggregateEntry{{Username: "alice"}}, Total: 40, Own: &own, OwnRow: 25}})
+	m = next.(model)
+	if m.boardLen() != 40 || ownRankText(m) != "You: #26 of 40 — 3 days played" {
+		t.Errorf("boardLen = %d, own rank %q, want 40 and #26", m.boardLen(), ownRankText(m))
+	}
+
+	// aggregate boards load page by page and aren't polled
+	if _, cmd := showPage(m, 2); cmd == nil {
+		t.Error("moving to another page of an aggregate board did not fetch it")
+	}
+	if _, cmd := m.Update(leaderboardPollMsg{}); cmd == nil {
+		t.Error("polling stopped on an aggregate tab")
+	}
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("[")})
+	if m.viewDateID != "" {
+		t.Errorf("[ changed the day on an aggregate tab")
+	}
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyTab})
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyTab})
+	if m.aggregateBoard() != "" || m.boardLen() != len(m.LeaderboardEntries) {
+		t.Errorf("tab from streaks should wrap to daily, got %q", m.aggregateBoard())
+	}
+}
+
+func TestCalendarNavigation(t *testing.T) {
+	c := newCalendarState("2026-03-31")
+	c.today = time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	c.moveMonth(-1)
+	if got := c.cursor.Format("2006-01-02"); got != "2026-02-28" {
+		t.Errorf("month back from March 31 = %s, want 2026-02-28", got)
+	}
+	c.move(7)
+	if got := c.cursor.Format("2006-01-02"); got != "2026-03-07" {
+		t.Errorf("week forward = %s, want 2026-03-07", got)
+	}
+	c.moveMonth(6)
+	if !c.cursor.Equal(c.today) {
+		t.Errorf("cursor moved past today to %s", c.cursor.Format("2006-01-02"))
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	results := []data.PlayerResult{{WPM: 40}, {WPM: 60}, {WPM: 80}, {WPM: 60}}
+	if got, want := sparkline(results), "▁▄█▄"; got != want {
+		t.Errorf("sparkline = %q, want %q", got, want)
+	}
+	if got, want := sparkline(results[:1]), "█"; got != want {
+		t.Errorf("single result sparkline = %q, want %q", got, want)
+	}
+}
+
+func TestProfileScreen(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	if m.profile == nil {
+		t.Fatal("u on the mode menu did not open the profile")
+	}
+	next, _ := m.Update(profileLoadedMsg{profile: &data.PlayerProfile{
+		DaysPlayed: 2,
+		Results:    []data.PlayerResult{{DateID: "2026-03-02", WPM: 70, Rank: 1, Total: 3}, {DateID: "2026-03-01", WPM: 50}},
+	}})
+	m = next.(model)
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyDown})
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyDown})
+	if m.profile.offset != 1 {
+		t.Errorf("scrolled to %d, want 1", m.profile.offset)
+	}
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.profile != nil || m.mode != nil {
+		t.Error("esc should go back to the mode menu")
+	}
+}
+
+func TestFindYourselfOnLeaderboard(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	m.entriesPerPage = 10
+	m.hasUserAlreadyDoneDailyChallenge = true
+	m, _ = startMode(m, gameModes[0])
+
+	entries := make([]leaderboardEntry, 10)
+	for i := range entries {
+		entries[i] = leaderboardEntry{UserID: fmt.Sprintf("player-%d", i), WPM: 100 - i, Rank: i + 1}
+	}
+	own := &leaderboardEntry{UserID: m.playerID, WPM: 76, Rank: 24}
+	next, _ := m.Update(leaderboardReceivedMsg{requested: m.viewDateID, LeaderboardEntries: entries, Total: 30, Own: own, OwnRow: 24})
+	m = next.(model)
+
+	if got, want := ownRankText(m), "You: #24 of 30 — 76 WPM"; got != want {
+		t.Errorf("ownRankText = %q, want %q", got, want)
+	}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	m = next.(model)
+	if m.currentPage != 2 || cmd == nil {
+		t.Fatalf("f jumped to page %d, want 2 and a fetch of it", m.currentPage)
+	}
+
+	// the first page arriving late mustn't replace the one asked for
+	next, _ = m.Update(leaderboardReceivedMsg{requested: m.viewDateID, LeaderboardEntries: entries[:3], Total: 31, Own: own, OwnRow: 24})
+	m = next.(model)
+	if m.boardTotal != 30 || len(m.LeaderboardEntries) != 10 {
+		t.Errorf("a stale first page replaced the board")
+	}
+	page := []leaderboardEntry{{UserID: "player-20", WPM: 80, Rank: 21}, *own}
+	next, _ = m.Update(leaderboardReceivedMsg{requested: m.viewDateID, offset: 20, LeaderboardEntries: page, Total: 30, Own: own, OwnRow: 24})
+	m = next.(model)
+	if m.boardOffset != 20 || boardEntryText(m, 20) == "" || m.boardRank(20) != 21 {
+		t.Errorf("page 2 not shown: offset %d", m.boardOffset)
+	}
+}
+
+// nextRaceUpdate runs a pending race command, waiting for the next change
+// to the room, and feeds the result back into the model.
+func nextRaceUpdate(t *testing.T, m model, cmd tea.Cmd) (model, tea.Cmd) {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("not waiting for race updates")
+	}
+	next, cmd := m.Update(cmd())
+	return next.(model), cmd
+}
+
+func TestRaceRoom(t *testing.T) {
+	countdown := raceHub.Countdown
+	raceHub.Countdown = 0
+	t.Cleanup(func() { raceHub.Countdown = countdown })
+
+	host := NewModel()
+	host.userSetUsername = true
+	host.username = "host_player"
+	host = sendKey(host, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if host.race == nil {
+		t.Fatal("r on the mode menu did not open the race screen")
+	}
+	next, hostCmd := host.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	host = next.(model)
+	if host.race.member == nil {
+		t.Fatalf("enter without a code did not create a room: %q", host.race.err)
+	}
+	host, hostCmd = nextRaceUpdate(t, host, hostCmd)
+
+	guest := NewModel()
+	guest.username = "guest_player"
+	guest.raceRequested = true
+	guest.raceCode = host.race.member.Code()
+	next, guestCmd := guest.Update(playerReceivedMsg{player: &data.Player{Username: guest.username}})
+	guest = next.(model)
+	if guest.race == nil || guest.race.member == nil {
+		t.Fatal("the race command did not join the room")
+	}
+	guest, guestCmd = nextRaceUpdate(t, guest, guestCmd)
+	host, hostCmd = nextRaceUpdate(t, host, hostCmd)
+	if len(host.race.snapshot.Players) != 2 {
+		t.Fatalf("host sees %d players, want 2", len(host.race.snapshot.Players))
+	}
+
+	next, _ = host.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	host = next.(model)
+	for guest.race.snapshot.State != race.Racing {
+		guest, guestCmd = nextRaceUpdate(t, guest, guestCmd)
+	}
+
+	text := guest.race.snapshot.Text
+	guest = sendKey(guest, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+	if !guest.race.session.Finished() {
+		t.Fatalf("typed %q of %q", guest.race.session.Typed(), text)
+	}
+	host = sendKey(host, tea.KeyMsg{Type: tea.KeyEsc})
+	if host.race != nil {
+		t.Error("esc did not leave the race")
+	}
+
+	for guest.race.snapshot.State != race.Finished {
+		guest, guestCmd = nextRaceUpdate(t, guest, guestCmd)
+	}
+	standings := guest.race.snapshot.Standings()
+	if standings[0].Username != "guest_player" || standings[0].Place != 1 || !standings[1].Left {
+		t.Errorf("standings = %+v", standings)
+	}
+}
+
+func TestGhostRace(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	keystrokes := []data.Keystroke{}
+	for i, key := range typing.Graphemes("go fast") {
+		keystrokes = append(keystrokes, data.Keystroke{OffsetMillis: int64(i) * 1000, Key: key})
+	}
+	m.personalBests = map[string]data.PersonalBest{
+		"words-10": {Mode: "words-10", Stats: data.RunStats{NetWPM: 12}, Text: "go fast", Keystrokes: keystrokes},
+		"words-25": {Mode: "words-25", Stats: data.RunStats{NetWPM: 80}},
+	}
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("g")})
+	if m.ghostMenu == nil || len(m.ghostMenu.options) != 1 {
+		t.Fatalf("g should list the one best with keystrokes: %+v", m.ghostMenu)
+	}
+	next, _ := m.Update(ghostLoadedMsg{ghost: &data.Ghost{
+		Name: "your best", Mode: "words-10", Text: "go fast", Keystrokes: keystrokes,
+		Stats: data.RunStats{NetWPM: 12, ElapsedMillis: 6000},
+	}})
+	m = next.(model)
+	if m.ghostMenu != nil || m.ghost == nil || m.mode.ranked() {
+		t.Fatal("picking a ghost did not start an unranked ghost race")
+	}
+
+	m.ghost.advance(2500 * time.Millisecond)
+	if got := ghostCaret(m); got != 3 {
+		t.Errorf("ghost caret after 2.5s at %d, want 3", got)
+	}
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("go")})
+	if got := ghostStatus(m); got != "👻 1 behind your best" {
+		t.Errorf("ghost status = %q", got)
+	}
+
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("fast")})
+	next, _ = m.Update(tickMsg{})
+	m = next.(model)
+	if !m.showingSummary || m.ghost.gap >= 0 {
+		t.Fatalf("finishing at once should beat a 6s ghost (summary %v, gap %v)", m.showingSummary, m.ghost.gap)
+	}
+	if view := m.View(); !strings.Contains(view, "You beat your best by") {
+		t.Errorf("summary does not show the gap:\n%s", view)
+	}
+
+	ghost := m.ghost
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.ghost == nil || m.ghost == ghost || m.session.Started() || ghostCaret(m) != 0 {
+		t.Error("enter should race the same ghost again from the start")
+	}
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != nil || m.ghost != nil {
+		t.Error("esc should leave the ghost race")
+	}
+}
+
+func TestDailyTextRefetchedAfterReset(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	m.dailyText = "yesterday's text"
+	m.dailyDateID = "2020-01-01"
+	m.hasUserAlreadyDoneDailyChallenge = true
+
+	m, cmd := startMode(m, gameModes[0])
+	if cmd == nil || m.session.Text() != "" || m.onLeaderboard() {
+		t.Fatalf("a stale daily text was played (text %q, cmd %v)", m.session.Text(), cmd != nil)
+	}
+	next, _ := m.Update(randomSentenceReceivedMsg{dateID: data.TodayID(), sentence: "today's text"})
+	m = next.(model)
+	if m.session.Text() != "today's text" || m.runDateID != data.TodayID() {
+		t.Errorf("run on %q for %s, want today's text", m.session.Text(), m.runDateID)
+	}
+}
+
+func TestReplayDropsStaleTicks(t *testing.T) {
+	m := NewModel()
+	m.userSetUsername = true
+	replay := &data.Replay{Username: "alice", DateID: "2026-03-01", Text: "hi", Keystrokes: []data.Keystroke{
+		{OffsetMillis: 0, Key: "h"}, {OffsetMillis: 100, Key: "i"},
+	}}
+
+	next, _ := m.Update(replayLoadedMsg{replay: replay})
+	m = next.(model)
+	first := m.replay.generation
+	m = sendKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+	next, _ = m.Update(replayLoadedMsg{replay: replay})
+	m = next.(model)
+
+	// the tick the first replay had in flight must not start a second loop
+	next, cmd := m.Update(replayTickMsg{generation: first, at: time.Now()})
+	m = next.(model)
+	if cmd != nil {
+		t.Error("a tick from a closed replay was rescheduled")
+	}
+	if _, cmd = m.Update(replayTickMsg{generation: m.replay.generation, at: time.Now()}); cmd == nil {
+		t.Error("the current replay's tick was not rescheduled")
+	}
+}
+
+func TestSpectate(t *testing.T) {
+	typist := NewModel()
+	typist.userSetUsername = true
+	typist.username = "typist_player"
+	typist.liveSession = liveSessions.Register(typist.sessionID)
+	t.Cleanup(func() { liveSessions.Unregister(typist.sessionID) })
+	typist, _ = startMode(typist, gameModes[modeByID(t, "words-10")])
+	next, _ := typist.Update(modeTextReceivedMsg{modeID: "words-10", text: "go fast"})
+	typist = next.(model)
+	typist = sendKey(typist, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("gx")})
+
+	spectator := NewModel()
+	spectator.userSetUsername = true
+	spectator = sendKey(spectator, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")})
+	if spectator.spectate == nil || len(spectator.spectate.typists) != 1 {
+		t.Fatalf("w should list the one player typing: %+v", spectator.spectate)
+	}
+	spectator = sendKey(spectator, tea.KeyMsg{Type: tea.KeyEnter})
+	if got := spectator.spectate.session.Typed(); got != "gx" {
+		t.Errorf("spectator sees %q typed, want gx", got)
+	}
+	_, states := spectator.spectate.session.Chars()
+	if states[1] != typing.CharIncorrect {
+		t.Error("spectator does not see the typist's mistake")
+	}
+
+	typist = sendKey(typist, tea.KeyMsg{Type: tea.KeyBackspace})
+	next, _ = spectator.Update(spectateTickMsg{spectate: spectator.spectate})
+	spectator = next.(model)
+	if got := spectator.spectate.session.Typed(); got != "g" {
+		t.Errorf("spectator sees %q typed after a backspace, want g", got)
+	}
+
+	// the daily challenge can't be watched before playing it
+	typist, _ = startMode(typist, gameModes[0])
+	next, _ = typist.Update(tickMsg{})
+	typist = next.(model)
+	next, _ = spectator.Update(spectateTickMsg{spectate: spectator.spectate})
+	spectator = next.(model)
+	if !spectator.spectate.gone {
+		t.Error("spectator can watch the daily challenge without having played it")
+	}
+	spectator = sendKey(spectator, tea.KeyMsg{Type: tea.KeyEsc})
+	if spectator.spectate == nil || len(spectator.spectate.typists) != 0 {
+		t.Errorf("the daily challenge is listed: %+v", spectator.spectate.typists)
+	}
+	spectator = sendKey(spectator, tea.KeyMsg{Type: tea.KeyEsc})
+	if spectator.spectate != nil {
+		t.Error("esc should close the spectate screen")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	data.InitInMemoryStore(t.TempDir())
+	t.Cleanup(data.Shutdown)
+	sentence, err := data.GetTodaysSentence()
+	if err != nil {
+		t.Fatalf("GetTodaysSentence: %v", err)
+	}
+	keystrokes := []data.Keystroke{}
+	for i, key := range typing.Graphemes(sentence) {
+		keystrokes = append(keystrokes, data.Keystroke{OffsetMillis: int64(i) * 200, Key: key})
+	}
+	if err := data.SubmitSentence(context.Background(), data.TodayID(), time.Now(), "player-1", "alice", data.RunStats{}, keystrokes); err != nil {
+		t.Fatalf("SubmitSentence: %v", err)
+	}
+
+	run := func(playerID string, args ...string) (int, string, string) {
+		var out, errOut bytes.Buffer
+		player := func() (string, error) { return playerID, nil }
+		code := runCommand(args, player, &out, &errOut)
+		return code, out.String(), errOut.String()
+	}
+
+	tests := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"leaderboard"}, 0, "alice"},
+		{[]string{"leaderboard", "2020-01-01"}, 0, "0 players"},
+		{[]string{"leaderboard", "today"}, 1, "2006-01-02"},
+		{[]string{"me"}, 0, "Days played  1"},
+		{[]string{"history"}, 0, data.TodayID()},
+		{[]string{"stats"}, 0, "Players         1"},
+		{[]string{"dance"}, 1, "unknown command"},
+		{[]string{}, 1, "Usage"},
+		{[]string{"me", "extra"}, 1, "Usage"},
+	}
+	for _, tt := range tests {
+		code, out, errOut := run("player-1", tt.args...)
+		if code != tt.code || !strings.Contains(out+errOut, tt.want) {
+			t.Errorf("%v = %d %q %q, want %d containing %q", tt.args, code, out, errOut, tt.code, tt.want)
+		}
+	}
+
+	// the text is only shown to players who have typed it
+	if _, out, _ := run("player-2", "sentence"); strings.Contains(out, sentence) {
+		t.Error("sentence gave today's text to a player who hasn't played")
+	}
+	if _, out, _ := run("player-1", "sentence"); !strings.Contains(out, sentence) {
+		t.Error("sentence did not show today's text after playing")
+	}
+
+	// callers who have never played have no profile to show
+	stranger := func(args ...string) (int, string) {
+		var out bytes.Buffer
+		code := runCommand(args, func() (string, error) { return "", data.ErrNoPlayer }, &out, &out)
+		return code, out.String()
+	}
+	for _, command := range []string{"me", "history"} {
+		if code, out := stranger(command); code != 0 || !strings.Contains(out, "No profile yet") {
+			t.Errorf("%s for a stranger = %d %q, want no profile yet", command, code, out)
+		}
+	}
+	if code, out := stranger("sentence"); code != 0 || strings.Contains(out, sentence) {
+		t.Errorf("sentence for a stranger = %d %q, want the challenge without its text", code, out)
+	}
+
+	code, out, _ := run("player-1", "leaderboard", "--json")
+	var board data.LeaderBoardResponse
+	if err := json.Unmarshal([]byte(out), &board); code != 0 || err != nil {
+		t.Fatalf("leaderboard --json = %d %q: %v", code, out, err)
+	}
+	if len(board.LeaderboardEntries) != 1 || board.Sentence != "" || board.LeaderboardEntries[0].FlagReason != "" {
+		t.Errorf("leaderboard --json = %+v, want alice without the text or flag details", board)
+	}
+}
+
+// fakeSession is an SSH session with just enough of the interface for
+// sessionIdentity.
+type fakeSession struct {
+	ssh.Session
+	user string
+	addr net.Addr
+	key  ssh.PublicKey
+}
+
+func (s fakeSession) User() string             { return s.user }
+func (s fakeSession) RemoteAddr() net.Addr     { return s.addr }
+func (s fakeSession) PublicKey() ssh.PublicKey { return s.key }
+
+func TestSessionIdentity(t *testing.T) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDtqJ7zOtqQtYqOo0CpvDXNlMhV3HeJDpjrASKGLWdop"))
+	if err != nil {
+		t.Fatalf("ParseAuthorizedKey: %v", err)
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 52000}
+
+	tests := []struct {
+		name          string
+		session       fakeSession
+		wantIdentity  string
+		wantAnonymous bool
+	}{
+		{"public key", fakeSession{user: "alice", addr: addr, key: key}, "pubkey:SHA256:tAXFyTXI8xtDaujAEcwJslAYc9/6FKcUkd2Lw0xDhPo", false},
+		{"same key, another name and address", fakeSession{user: "bob", addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 22}, key: key}, "pubkey:SHA256:tAXFyTXI8xtDaujAEcwJslAYc9/6FKcUkd2Lw0xDhPo", false},
+		{"no key", fakeSession{user: "alice", addr: addr}, "anon:alice-203.0.113.7", true},
+		{"no key or user", fakeSession{addr: addr}, "anon:anonymous-203.0.113.7", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, anonymous := sessionIdentity(tt.session)
+			if identity != tt.wantIdentity || anonymous != tt.wantAnonymous {
+				t.Errorf("sessionIdentity = %q, %v, want %q, %v", identity, anonymous, tt.wantIdentity, tt.wantAnonymous)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"monkeyy/data"
+	"monkeyy/typing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+// gameMode is one of the ways to play. Only the daily challenge is ranked;
+// every other mode keeps a personal best per player.
+type gameMode struct {
+	ID   string
+	Name string
+	// Words is the number of generated words in word-count modes.
+	Words int
+	// Duration is the time limit of timed modes, which run on endless
+	// generated words.
+	Duration time.Duration
+	// Practice modes type sentences from the sentence source.
+	Practice bool
+}
+
+var gameModes = []gameMode{
+	{ID: data.DailyMode, Name: "Daily challenge"},
+	{ID: "time-15", Name: "15 seconds", Duration: 15 * time.Second},
+	{ID: "time-30", Name: "30 seconds", Duration: 30 * time.Second},
+	{ID: "time-60", Name: "60 seconds", Duration: 60 * time.Second},
+	{ID: "time-120", Name: "120 seconds", Duration: 120 * time.Second},
+	{ID: "words-10", Name: "10 words", Words: 10},
+	{ID: "words-25", Name: "25 words", Words: 25},
+	{ID: "words-50", Name: "50 words", Words: 50},
+	{ID: "words-100", Name: "100 words", Words: 100},
+	{ID: "practice", Name: "Free practice", Practice: true},
+}
+
+// recentPracticeRuns is how many practice runs the summary screen lists.
+const recentPracticeRuns = 5
+
+const (
+	// endlessWordBatch words are generated at a time for timed modes, and
+	// more are added once fewer than endlessRefillChars characters are left.
+	endlessWordBatch   = 50
+	endlessRefillChars = 80
+)
+
+type modeTextReceivedMsg struct {
+	modeID string
+	text   string
+	err    error
+}
+
+type personalBestsLoadedMsg struct {
+	bests map[string]data.PersonalBest
+}
+
+type personalBestSavedMsg struct {
+	best  data.PersonalBest
+	isNew bool
+	err   error
+}
+
+type practiceRecordedMsg struct {
+	history []data.PracticeResult
+	err     error
+}
+
+func (mode gameMode) ranked() bool {
+	return mode.ID == data.DailyMode
+}
+
+func modeTextCmd(mode gameMode) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in modeTextCmd", "panic", r, "mode", mode.ID)
+			}
+		}()
+
+		switch {
+		case mode.Practice:
+			text, err := data.GetLongSentence()
+			if err != nil {
+				log.Error("Error fetching practice text", "error", err)
+			}
+			return modeTextReceivedMsg{modeID: mode.ID, text: text, err: err}
+		case mode.Duration > 0:
+			return modeTextReceivedMsg{modeID: mode.ID, text: data.RandomWords(endlessWordBatch)}
+		default:
+			return modeTextReceivedMsg{modeID: mode.ID, text: data.RandomWords(mode.Words)}
+		}
+	}
+}
+
+func fetchPersonalBestsCmd(playerID string) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in fetchPersonalBestsCmd", "panic", r, "player_id", playerID)
+			}
+		}()
+
+		bests, err := data.GetPersonalBests(playerID)
+		if err != nil {
+			log.Error("Error fetching personal bests", "error", err, "player_id", playerID)
+		}
+		return personalBestsLoadedMsg{bests: bests}
+	}
+}
+
+// savePersonalBestCmd keeps the run's text and keystrokes along with a new
+// personal best so it can be raced as a ghost.
+func savePersonalBestCmd(playerID string, modeID string, text string, stats data.RunStats, keystrokes []data.Keystroke) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in savePersonalBestCmd", "panic", r, "player_id", playerID, "mode", modeID)
+			}
+		}()
+
+		best, isNew, err := data.SavePersonalBest(context.Background(), playerID, modeID, text, stats, keystrokes)
+		if err != nil {
+			log.Error("Error saving personal best", "error", err, "player_id", playerID, "mode", modeID)
+		} else if isNew {
+			log.Info("New personal best", "player_id", playerID, "mode", modeID, "wpm", stats.NetWPM)
+		}
+		return personalBestSavedMsg{best: best, isNew: isNew, err: err}
+	}
+}
+
+// recordPracticeCmd adds an unranked run to the practice history and returns
+// the player's most recent practice runs.
+func recordPracticeCmd(playerID string, modeID string, text string, stats data.RunStats) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in recordPracticeCmd", "panic", r, "player_id", playerID, "mode", modeID)
+			}
+		}()
+
+		if err := data.RecordPracticeRun(context.Background(), playerID, modeID, text, stats); err != nil {
+			log.Error("Error recording practice run", "error", err, "player_id", playerID, "mode", modeID)
+			return practiceRecordedMsg{err: err}
+		}
+		history, err := data.GetPracticeHistory(playerID, recentPracticeRuns)
+		if err != nil {
+			log.Error("Error fetching practice history", "error", err, "player_id", playerID)
+		}
+		return practiceRecordedMsg{history: history, err: err}
+	}
+}
+
+// resetRun clears the state of the previous run for a new one in mode.
+func resetRun(m model, mode gameMode) model {
+	m.mode = &mode
+	m.WPM = 0
+	m.runDone = false
+	m.runStats = data.RunStats{}
+	m.submitError = ""
+	m.newBest = false
+	m.ghost = nil
+	return m
+}
+
+// startMode resets the run state and starts a run in mode. The daily
+// challenge goes straight to the leaderboard once it has been played.
+func startMode(m model, mode gameMode) (model, tea.Cmd) {
+	m = resetRun(m, mode)
+
+	if mode.ranked() {
+		// a session left open past the reset fetches the new day's text,
+		// and whether it has been played, before a run can start
+		if m.dailyDateID != data.TodayID() {
+			m.hasUserAlreadyDoneDailyChallenge = false
+			m.session = typing.NewSession("", nil)
+			return m, tea.Batch(getRandomSentenceCmd(), fetchUserDailyChallengeStatusCmd(m.playerID))
+		}
+		if m.hasUserAlreadyDoneDailyChallenge {
+			return m, fetchLeaderBoardPage(m)
+		}
+		m.session = typing.NewSession(m.dailyText, nil)
+		m.runDateID = m.dailyDateID
+		return m, nil
+	}
+
+	// An empty session takes no input until the text arrives.
+	m.session = typing.NewSession("", nil)
+	return m, modeTextCmd(mode)
+}
+
+// startPractice starts a free practice run from the leaderboard, which is
+// where leaving it returns to.
+func startPractice(m model) (model, tea.Cmd) {
+	for _, mode := range gameModes {
+		if mode.Practice {
+			m.backToLeaderboard = true
+			return startMode(m, mode)
+		}
+	}
+	return m, nil
+}
+
+// leaveMode returns to the mode selection screen, or to the leaderboard for
+// practice started from there.
+func leaveMode(m model) (model, tea.Cmd) {
+	if m.backToLeaderboard {
+		m.backToLeaderboard = false
+		return startMode(m, gameModes[0])
+	}
+	m.mode = nil
+	m.ghost = nil
+	return m, fetchPersonalBestsCmd(m.playerID)
+}
+
+func leaveModeHint(m model) string {
+	if m.backToLeaderboard {
+		return "back to the leaderboard"
+	}
+	return "choose another mode"
+}
+
+// finishRun records a finished run: daily runs are submitted to the
+// leaderboard, ghost races are only compared with the ghost, and everything
+// else goes to the practice history and is checked against the personal
+// best.
+func finishRun(m model) (model, tea.Cmd) {
+	m.runDone = true
+	m.runStats = m.session.Stats()
+	m.WPM = int(m.runStats.NetWPM)
+	m.showingSummary = true
+
+	if m.ghost != nil {
+		finishGhostRace(m)
+		return m, nil
+	}
+	if m.mode.ranked() {
+		if m.runDateID == data.TodayID() {
+			m.hasUserAlreadyDoneDailyChallenge = true
+		}
+		return m, submitSentenceCmd(m.runDateID, m.session.StartTime(), m.playerID, m.username, m.runStats, m.session.Keystrokes())
+	}
+	return m, tea.Batch(
+		savePersonalBestCmd(m.playerID, m.mode.ID, m.session.Text(), m.runStats, m.session.Keystrokes()),
+		recordPracticeCmd(m.playerID, m.mode.ID, m.session.Text(), m.runStats),
+	)
+}
+
+func updateModeSelect(m model, msg tea.KeyMsg) (model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.modeCursor > 0 {
+			m.modeCursor--
+		}
+	case "down", "j":
+		if m.modeCursor < len(gameModes)-1 {
+			m.modeCursor++
+		}
+	case "enter":
+		return startMode(m, gameModes[m.modeCursor])
+	case "u":
+		return openProfile(m)
+	case "r":
+		return openRace(m, "")
+	case "g":
+		return openGhostMenu(m)
+	case "w":
+		return openSpectate(m)
+	}
+	return m, nil
+}
+
+func renderModeSelect(m model) string {
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Bold(true)
+	rowStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#3b82f6"))
+	detailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+
+	modeDisplay := []string{}
+	if m.welcomeMessage != "" {
+		modeDisplay = append(modeDisplay, m.statsStyle.Render(m.welcomeMessage), "")
+	}
+	modeDisplay = append(modeDisplay, titleStyle.Render("⌨  Choose a mode"), "")
+
+	for i, mode := range gameModes {
+		detail := ""
+		if best, ok := m.personalBests[mode.ID]; ok {
+			detail = fmt.Sprintf("best %.0f WPM", best.Stats.NetWPM)
+		}
+		if mode.ranked() {
+			if m.hasUserAlreadyDoneDailyChallenge {
+				detail = "ranked · done today ✓"
+			} else {
+				detail = "ranked · once a day"
+			}
+		}
+
+		line := fmt.Sprintf(" %-20s", mode.Name)
+		if i == m.modeCursor {
+			line = selectedStyle.Render(line)
+		} else {
+			line = rowStyle.Render(line)
+		}
+		modeDisplay = append(modeDisplay, line+"  "+detailStyle.Render(detail))
+	}
+
+	modeDisplay = append(modeDisplay, "", detailStyle.Render("↑ ↓: select | enter: play | r: race friends | g: race a ghost | w: watch | u: your profile"))
+	return lipgloss.JoinVertical(lipgloss.Left, modeDisplay...)
+}
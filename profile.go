@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"monkeyy/data"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+// profileHistoryLimit is how many past days the profile screen loads, as
+// many as a history can hold.
+const profileHistoryLimit = data.MaxHistoryResults
+
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+type profileState struct {
+	profile *data.PlayerProfile
+	loaded  bool
+	err     string
+	offset  int
+}
+
+type profileLoadedMsg struct {
+	profile *data.PlayerProfile
+	err     error
+}
+
+func fetchProfileCmd(playerID string) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in fetchProfileCmd", "panic", r, "player_id", playerID)
+			}
+		}()
+
+		profile, err := data.GetPlayerProfile(playerID, profileHistoryLimit)
+		if err != nil {
+			log.Error("Error fetching profile", "error", err, "player_id", playerID)
+		}
+		return profileLoadedMsg{profile: profile, err: err}
+	}
+}
+
+func openProfile(m model) (model, tea.Cmd) {
+	m.profile = &profileState{}
+	return m, fetchProfileCmd(m.playerID)
+}
+
+func updateProfile(m model, msg tea.Msg) (model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case profileLoadedMsg:
+		m.profile.loaded = true
+		if msg.err != nil {
+			m.profile.err = "Could not load your profile"
+			return m, nil
+		}
+		m.profile.profile = msg.profile
+
+	case tea.KeyMsg:
+		rows := 0
+		if m.profile.profile != nil {
+			rows = len(m.profile.profile.Results)
+		}
+		switch msg.String() {
+		case "esc", "q":
+			m.profile = nil
+		case "up", "k":
+			if m.profile.offset > 0 {
+				m.profile.offset--
+			}
+		case "down", "j":
+			if m.profile.offset < rows-1 {
+				m.profile.offset++
+			}
+		}
+	}
+	return m, nil
+}
+
+// sparkline draws one bar per result, oldest first, scaled between the
+// slowest and fastest of them.
+func sparkline(results []data.PlayerResult) string {
+	if len(results) == 0 {
+		return ""
+	}
+	low, high := results[0].WPM, results[0].WPM
+	for _, result := range results {
+		low = min(low, result.WPM)
+		high = max(high, result.WPM)
+	}
+
+	bars := make([]rune, 0, len(results))
+	for _, result := range results {
+		level := len(sparkBars) - 1
+		if high > low {
+			level = (result.WPM - low) * (len(sparkBars) - 1) / (high - low)
+		}
+		bars = append(bars, sparkBars[level])
+	}
+	return string(bars)
+}
+
+func renderProfile(m model) string {
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Bold(true)
+	rowStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
+	detailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	sparkStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#3b82f6"))
+	rejectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444"))
+
+	profileDisplay := []string{titleStyle.Render("👤 " + m.username), ""}
+	controls := detailStyle.Render("↑ ↓: scroll | esc: back")
+
+	switch {
+	case m.profile.err != "":
+		return lipgloss.JoinVertical(lipgloss.Left, append(profileDisplay, detailStyle.Render(m.profile.err), "", controls)...)
+	case !m.profile.loaded:
+		return lipgloss.JoinVertical(lipgloss.Left, append(profileDisplay, detailStyle.Render("Loading..."), "", controls)...)
+	}
+
+	profile := m.profile.profile
+	if profile.DaysPlayed == 0 {
+		profileDisplay = append(profileDisplay, detailStyle.Italic(true).Render("   No daily challenges played yet"))
+	} else {
+		profileDisplay = append(profileDisplay,
+			rowStyle.Render(fmt.Sprintf("Daily best %d WPM on %s | average %.1f WPM over %d days", profile.BestWPM, profile.BestDateID, profile.AverageWPM, profile.DaysPlayed)),
+			rowStyle.Render(fmt.Sprintf("Faster than %.0f%% of players", profile.Percentile)),
+		)
+	}
+
+	bests := []string{}
+	for _, mode := range gameModes {
+		if best, ok := m.personalBests[mode.ID]; ok && !mode.ranked() {
+			bests = append(bests, fmt.Sprintf("%s %.0f", mode.Name, best.Stats.NetWPM))
+		}
+	}
+	if len(bests) > 0 {
+		line := "Personal bests:"
+		for _, best := range bests {
+			line += "  " + best
+		}
+		profileDisplay = append(profileDisplay, detailStyle.Render(line))
+	}
+
+	if len(profile.Results) > 0 {
+		chronological := slices.Clone(profile.Results)
+		slices.Reverse(chronological)
+		if width := m.width - 4; width > 0 && len(chronological) > width {
+			chronological = chronological[len(chronological)-width:]
+		}
+		profileDisplay = append(profileDisplay,
+			"",
+			detailStyle.Render(fmt.Sprintf("WPM over the last %d days played", len(chronological))),
+			sparkStyle.Render(sparkline(chronological)),
+			"",
+			detailStyle.Render(fmt.Sprintf(" %-10s  %4s  %-12s  %6s", "Date", "WPM", "Rank", "Acc")),
+		)
+
+		// header, stats, chart and controls take about a dozen lines
+		visible := max(m.height-14, 5)
+		end := min(m.profile.offset+visible, len(profile.Results))
+		for _, result := range profile.Results[m.profile.offset:end] {
+			rank := fmt.Sprintf("#%d of %d", result.Rank, result.Total)
+			style := rowStyle
+			if result.Rank == 0 {
+				rank = result.Status
+				style = rejectedStyle
+			}
+			profileDisplay = append(profileDisplay, style.Render(fmt.Sprintf(" %-10s  %4d  %-12s  %5.1f%%", result.DateID, result.WPM, rank, result.Accuracy)))
+		}
+	}
+
+	profileDisplay = append(profileDisplay, "", controls)
+	return lipgloss.JoinVertical(lipgloss.Left, profileDisplay...)
+}
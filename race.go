@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"monkeyy/data"
+	"monkeyy/race"
+	"monkeyy/typing"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	raceWordCount   = 30
+	raceBarWidth    = 30
+	raceNameColumns = 20 // the longest username allowed
+)
+
+// raceHub holds the race rooms of every session on this server.
+var raceHub = race.NewHub(func() string { return data.RandomWords(raceWordCount) })
+
+type raceState struct {
+	codeInput textinput.Model
+	member    *race.Member
+	snapshot  race.Snapshot
+	session   *typing.Session
+	err       string
+}
+
+// raceUpdateMsg carries a room change to the member it was sent to;
+// raceClosedMsg says the member has left the room.
+type raceUpdateMsg struct {
+	member   *race.Member
+	snapshot race.Snapshot
+}
+
+type raceClosedMsg struct {
+	member *race.Member
+}
+
+func waitForRaceCmd(member *race.Member) tea.Cmd {
+	return func() tea.Msg {
+		snapshot, ok := <-member.Updates
+		if !ok {
+			return raceClosedMsg{member: member}
+		}
+		return raceUpdateMsg{member: member, snapshot: snapshot}
+	}
+}
+
+func createCodeInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "join code"
+	ti.CharLimit = 8
+	ti.Width = 12
+	ti.Focus()
+	return ti
+}
+
+// openRace shows the race screen, joining the room with the given code
+// straight away if there is one.
+func openRace(m model, code string) (model, tea.Cmd) {
+	m.race = &raceState{codeInput: createCodeInput()}
+	if code == "" {
+		return m, textinput.Blink
+	}
+	return joinRace(m, code)
+}
+
+// openRequestedRace opens the race screen if the session was started with
+// the race command.
+func openRequestedRace(m model) (model, tea.Cmd) {
+	if !m.raceRequested {
+		return m, nil
+	}
+	m.raceRequested = false
+	return openRace(m, m.raceCode)
+}
+
+func joinRace(m model, code string) (model, tea.Cmd) {
+	var member *race.Member
+	if code == "" {
+		member = raceHub.Create(m.sessionID, m.username)
+	} else {
+		var err error
+		if member, err = raceHub.Join(code, m.sessionID, m.username); err != nil {
+			m.race.err = err.Error()
+			return m, nil
+		}
+	}
+	m.race.member = member
+	m.race.err = ""
+	return m, waitForRaceCmd(member)
+}
+
+func leaveRace(m model) (model, tea.Cmd) {
+	if m.race.member != nil {
+		m.race.member.Leave()
+	}
+	m.race = nil
+	return m, nil
+}
+
+func updateRace(m model, msg tea.Msg) (model, tea.Cmd) {
+	r := m.race
+	switch msg := msg.(type) {
+	case raceUpdateMsg:
+		if msg.member != r.member {
+			return m, nil
+		}
+		r.snapshot = msg.snapshot
+		// every new race in the room starts on a fresh text
+		if r.session == nil || r.session.Text() != r.snapshot.Text || (r.snapshot.State < race.Racing && r.session.Started()) {
+			r.session = typing.NewSession(r.snapshot.Text, nil)
+		}
+		return m, waitForRaceCmd(r.member)
+
+	case raceClosedMsg:
+		if msg.member == r.member {
+			m.race = nil
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "esc" {
+			return leaveRace(m)
+		}
+		if r.member == nil {
+			if msg.String() == "enter" {
+				return joinRace(m, strings.TrimSpace(r.codeInput.Value()))
+			}
+			var cmd tea.Cmd
+			r.codeInput, cmd = r.codeInput.Update(msg)
+			return m, cmd
+		}
+
+		r.err = ""
+		switch r.snapshot.State {
+		case race.Waiting:
+			if msg.String() == "enter" {
+				if err := r.member.Start(); err != nil {
+					r.err = err.Error()
+				}
+			}
+		case race.Finished:
+			if msg.String() == "enter" {
+				if err := r.member.Restart(); err != nil {
+					r.err = err.Error()
+				}
+			}
+		case race.Racing:
+			typeInRace(r, msg)
+		}
+	}
+	return m, nil
+}
+
+// typeInRace applies a key press to the player's own text and tells the
+// room how far they have got.
+func typeInRace(r *raceState, msg tea.KeyMsg) {
+	if r.session == nil || r.session.Finished() {
+		return
+	}
+	if msg.Type == tea.KeyBackspace {
+		r.session.Backspace()
+	} else {
+		keys := typedKeys(msg)
+		if len(keys) == 0 {
+			return
+		}
+		for _, key := range keys {
+			r.session.Type(key, time.Now())
+		}
+	}
+
+	if r.session.Finished() {
+		r.member.Finish(r.session.Stats())
+		return
+	}
+	progress, _ := r.session.Progress()
+	r.member.Progress(progress, r.session.Stats().NetWPM)
+}
+
+// progressBar draws how far through the text a player is.
+func progressBar(progress int, length int) string {
+	filled := 0
+	if length > 0 {
+		filled = min(raceBarWidth, progress*raceBarWidth/length)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", raceBarWidth-filled)
+}
+
+func renderRace(m model) string {
+	r := m.race
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Bold(true)
+	rowStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
+	ownStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#3b82f6")).Bold(true)
+	detailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444"))
+
+	if r.member == nil {
+		raceDisplay := []string{
+			titleStyle.Render("🏁 Race"),
+			"",
+			rowStyle.Render("Enter a join code, or leave it empty to open a new room:"),
+			r.codeInput.View(),
+		}
+		if r.err != "" {
+			raceDisplay = append(raceDisplay, "", errorStyle.Render(r.err))
+		}
+		raceDisplay = append(raceDisplay, "", detailStyle.Render("enter: join or create | esc: back"))
+		return lipgloss.JoinVertical(lipgloss.Left, raceDisplay...)
+	}
+
+	snapshot := r.snapshot
+	isHost := snapshot.Host == m.sessionID
+	raceDisplay := []string{
+		titleStyle.Render("🏁 Race " + r.member.Code()),
+		detailStyle.Render(fmt.Sprintf("Others can join with code %s, or with: ssh -t <this server> race %s", r.member.Code(), r.member.Code())),
+		"",
+	}
+
+	players := snapshot.Players
+	if snapshot.State == race.Finished {
+		players = snapshot.Standings()
+	}
+	for _, player := range players {
+		status := fmt.Sprintf("%3.0f WPM", player.WPM)
+		switch {
+		case player.Finished:
+			status = fmt.Sprintf("%3.0f WPM  #%d  %.2fs  %.1f%%", player.WPM, player.Place, player.FinishTime.Seconds(), player.Accuracy)
+		case player.Left:
+			status = "left"
+		case snapshot.State == race.Finished:
+			status = "did not finish"
+		}
+		line := fmt.Sprintf("%-*s %s %s", raceNameColumns, player.Username, progressBar(player.Progress, snapshot.Length), status)
+		if player.ID == m.sessionID {
+			raceDisplay = append(raceDisplay, ownStyle.Render(line))
+		} else {
+			raceDisplay = append(raceDisplay, rowStyle.Render(line))
+		}
+	}
+	raceDisplay = append(raceDisplay, "")
+
+	controls := "esc: leave"
+	switch snapshot.State {
+	case race.Waiting:
+		if isHost {
+			raceDisplay = append(raceDisplay, rowStyle.Render("Press enter when everyone is here"))
+			controls = "enter: start | " + controls
+		} else {
+			raceDisplay = append(raceDisplay, rowStyle.Render("Waiting for the host to start..."))
+		}
+	case race.Countdown:
+		seconds := math.Ceil(time.Until(snapshot.StartsAt).Seconds())
+		raceDisplay = append(raceDisplay, m.statsStyle.Render(fmt.Sprintf("Starting in %.0f...", max(seconds, 1))), "")
+		if r.session != nil {
+			raceDisplay = append(raceDisplay, m.normalStyle.Render(r.session.Text()))
+		}
+	case race.Racing:
+		raceDisplay = append(raceDisplay, m.statsStyle.Render("Go!"), "", renderSessionText(m, r.session, -1))
+		if r.session.Finished() {
+			raceDisplay = append(raceDisplay, "", rowStyle.Render("Finished! Waiting for the others..."))
+		}
+	case race.Finished:
+		raceDisplay = append(raceDisplay, m.statsStyle.Render("Race over"))
+		if isHost {
+			controls = "enter: race again | " + controls
+		} else {
+			raceDisplay = append(raceDisplay, rowStyle.Render("Waiting for the host to start another race..."))
+		}
+	}
+
+	if r.err != "" {
+		raceDisplay = append(raceDisplay, "", errorStyle.Render(r.err))
+	}
+	raceDisplay = append(raceDisplay, "", detailStyle.Render(controls))
+	return lipgloss.JoinVertical(lipgloss.Left, raceDisplay...)
+}
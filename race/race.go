@@ -0,0 +1,438 @@
+// Package race runs multiplayer typing races. A Hub keeps the open rooms in
+// memory and fans every change in a room out to the sessions taking part,
+// so it only works for players connected to the same server process.
+package race
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"monkeyy/typing"
+)
+
+// MaxPlayers is how many sessions can take part in one race.
+const MaxPlayers = 8
+
+// Join codes avoid letters and digits that are easy to mix up.
+const (
+	codeLength   = 4
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var (
+	ErrRoomNotFound  = errors.New("no race with that code")
+	ErrRoomFull      = errors.New("that race is full")
+	ErrRaceStarted   = errors.New("that race has already started")
+	ErrAlreadyJoined = errors.New("already in that race")
+	ErrNotHost       = errors.New("only the host can do that")
+)
+
+// State is where a room is in its race.
+type State int
+
+const (
+	Waiting State = iota
+	Countdown
+	Racing
+	Finished
+)
+
+// Player is one participant as everyone in the room sees them. Progress
+// counts the characters typed correctly so far.
+type Player struct {
+	ID         string
+	Username   string
+	Progress   int
+	WPM        float64
+	Accuracy   float64
+	Finished   bool
+	FinishTime time.Duration
+	Place      int
+	Left       bool
+}
+
+// Snapshot is the state of a room at one moment. Length is the number of
+// characters in Text.
+type Snapshot struct {
+	Code     string
+	Host     string
+	State    State
+	Text     string
+	Length   int
+	StartsAt time.Time
+	Players  []Player
+}
+
+// Standings orders the players for a results table: finishers by place,
+// then everyone else by how far they got.
+func (s Snapshot) Standings() []Player {
+	players := slices.Clone(s.Players)
+	slices.SortStableFunc(players, func(a, b Player) int {
+		switch {
+		case a.Finished && b.Finished:
+			return a.Place - b.Place
+		case a.Finished:
+			return -1
+		case b.Finished:
+			return 1
+		}
+		return b.Progress - a.Progress
+	})
+	return players
+}
+
+// Hub holds every open room. Rooms are removed once their last member
+// leaves.
+type Hub struct {
+	// Countdown is the time between the host starting a race and typing
+	// being allowed. TimeLimit ends races that not everyone finishes.
+	Countdown time.Duration
+	TimeLimit time.Duration
+
+	text func() string
+
+	mu    sync.Mutex
+	rooms map[string]*room
+}
+
+type room struct {
+	code     string
+	host     string
+	state    State
+	text     string
+	length   int
+	startsAt time.Time
+	players  []Player
+	members  map[string]chan Snapshot
+	timer    *time.Timer
+	// round changes every time the room is reset, so timers from an
+	// earlier race can tell they are stale.
+	round int
+}
+
+// NewHub returns an empty hub whose races are typed on texts from text.
+func NewHub(text func() string) *Hub {
+	return &Hub{
+		Countdown: 5 * time.Second,
+		TimeLimit: 5 * time.Minute,
+		text:      text,
+		rooms:     map[string]*room{},
+	}
+}
+
+// Member is one session's place in a room. Every change to the room is sent
+// on Updates, which only ever holds the latest snapshot and is closed when
+// the member leaves.
+type Member struct {
+	hub     *Hub
+	code    string
+	id      string
+	Updates <-chan Snapshot
+}
+
+// Code returns the join code of the member's room.
+func (m *Member) Code() string {
+	return m.code
+}
+
+// ID returns the session ID the member joined with.
+func (m *Member) ID() string {
+	return m.id
+}
+
+// Create opens a new room with the session id as its host.
+func (h *Hub) Create(id string, username string) *Member {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	code := newCode()
+	for h.rooms[code] != nil {
+		code = newCode()
+	}
+	r := &room{code: code, host: id, members: map[string]chan Snapshot{}}
+	r.reset(h.text())
+	h.rooms[code] = r
+	return h.join(r, id, username)
+}
+
+// Join adds the session id to the room with the given code. Codes are not
+// case sensitive.
+func (h *Hub) Join(code string, id string, username string) (*Member, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[strings.ToUpper(strings.TrimSpace(code))]
+	switch {
+	case r == nil:
+		return nil, ErrRoomNotFound
+	case r.members[id] != nil:
+		return nil, ErrAlreadyJoined
+	case r.state == Countdown || r.state == Racing:
+		return nil, ErrRaceStarted
+	case len(r.members) >= MaxPlayers:
+		return nil, ErrRoomFull
+	}
+	return h.join(r, id, username), nil
+}
+
+func (h *Hub) join(r *room, id string, username string) *Member {
+	updates := make(chan Snapshot, 1)
+	r.members[id] = updates
+	r.players = append(r.players, Player{ID: id, Username: username})
+	r.broadcast()
+	return &Member{hub: h, code: r.code, id: id, Updates: updates}
+}
+
+// LeaveSession takes the session id out of every room it is in, for when
+// its connection closes.
+func (h *Hub) LeaveSession(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, r := range h.rooms {
+		if r.members[id] != nil {
+			h.leave(r, id)
+		}
+	}
+}
+
+// Start begins the countdown. Only the host can start a race.
+func (m *Member) Start() error {
+	h := m.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[m.code]
+	switch {
+	case r == nil || r.members[m.id] == nil:
+		return ErrRoomNotFound
+	case r.host != m.id:
+		return ErrNotHost
+	case r.state != Waiting:
+		return ErrRaceStarted
+	}
+
+	r.state = Countdown
+	r.startsAt = time.Now().Add(h.Countdown)
+	round := r.round
+	r.timer = time.AfterFunc(h.Countdown, func() { h.begin(r, round) })
+	r.broadcast()
+	return nil
+}
+
+// begin lets the racers type once the countdown is over.
+func (h *Hub) begin(r *room, round int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if r.round != round || r.state != Countdown {
+		return
+	}
+	r.state = Racing
+	r.timer = time.AfterFunc(h.TimeLimit, func() { h.timeout(r, round) })
+	r.broadcast()
+}
+
+func (h *Hub) timeout(r *room, round int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if r.round == round && r.state == Racing {
+		r.finish()
+	}
+}
+
+// Progress reports how many characters the member has typed correctly and
+// their speed so far.
+func (m *Member) Progress(progress int, wpm float64) {
+	h := m.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[m.code]
+	if r == nil || r.state != Racing {
+		return
+	}
+	if i := r.player(m.id); i >= 0 && !r.players[i].Finished {
+		r.players[i].Progress = min(progress, r.length)
+		r.players[i].WPM = wpm
+		r.broadcast()
+	}
+}
+
+// Finish records that the member typed the whole text. The race ends when
+// everyone still in it has finished.
+func (m *Member) Finish(stats typing.Stats) {
+	h := m.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[m.code]
+	if r == nil || r.state != Racing {
+		return
+	}
+	i := r.player(m.id)
+	if i < 0 || r.players[i].Finished {
+		return
+	}
+
+	place := 1
+	for _, player := range r.players {
+		if player.Finished {
+			place++
+		}
+	}
+	r.players[i].Finished = true
+	r.players[i].Place = place
+	r.players[i].Progress = r.length
+	r.players[i].WPM = stats.NetWPM
+	r.players[i].Accuracy = stats.Accuracy
+	r.players[i].FinishTime = time.Since(r.startsAt)
+
+	if r.everyoneFinished() {
+		r.finish()
+		return
+	}
+	r.broadcast()
+}
+
+// Restart sets a finished room up for another race on a new text, keeping
+// the players who are still there. Only the host can restart a race.
+func (m *Member) Restart() error {
+	h := m.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[m.code]
+	switch {
+	case r == nil || r.members[m.id] == nil:
+		return ErrRoomNotFound
+	case r.host != m.id:
+		return ErrNotHost
+	case r.state != Finished:
+		return ErrRaceStarted
+	}
+
+	r.reset(h.text())
+	r.broadcast()
+	return nil
+}
+
+// Leave takes the member out of the room. Players who leave mid-race stay in
+// the results as not finished.
+func (m *Member) Leave() {
+	h := m.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if r := h.rooms[m.code]; r != nil && r.members[m.id] != nil {
+		h.leave(r, m.id)
+	}
+}
+
+func (h *Hub) leave(r *room, id string) {
+	close(r.members[id])
+	delete(r.members, id)
+
+	if i := r.player(id); i >= 0 {
+		if r.state == Countdown || r.state == Racing {
+			r.players[i].Left = true
+		} else {
+			r.players = slices.Delete(r.players, i, i+1)
+		}
+	}
+
+	if len(r.members) == 0 {
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+		delete(h.rooms, r.code)
+		return
+	}
+	if r.host == id {
+		for _, player := range r.players {
+			if r.members[player.ID] != nil {
+				r.host = player.ID
+				break
+			}
+		}
+	}
+	if r.state == Racing && r.everyoneFinished() {
+		r.finish()
+		return
+	}
+	r.broadcast()
+}
+
+func (r *room) reset(text string) {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.round++
+	r.state = Waiting
+	r.text = text
+	r.length = len(typing.Graphemes(text))
+	r.startsAt = time.Time{}
+
+	players := r.players[:0]
+	for _, player := range r.players {
+		if r.members[player.ID] != nil {
+			players = append(players, Player{ID: player.ID, Username: player.Username})
+		}
+	}
+	r.players = players
+}
+
+func (r *room) finish() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.state = Finished
+	r.broadcast()
+}
+
+func (r *room) player(id string) int {
+	return slices.IndexFunc(r.players, func(player Player) bool { return player.ID == id })
+}
+
+func (r *room) everyoneFinished() bool {
+	for _, player := range r.players {
+		if !player.Finished && !player.Left {
+			return false
+		}
+	}
+	return true
+}
+
+// broadcast sends the room's current state to every member, replacing any
+// snapshot they haven't read yet. It must be called with the hub locked.
+func (r *room) broadcast() {
+	snapshot := Snapshot{
+		Code:     r.code,
+		Host:     r.host,
+		State:    r.state,
+		Text:     r.text,
+		Length:   r.length,
+		StartsAt: r.startsAt,
+		Players:  slices.Clone(r.players),
+	}
+	for _, updates := range r.members {
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- snapshot
+	}
+}
+
+func newCode() string {
+	code := make([]byte, codeLength)
+	for i := range code {
+		code[i] = codeAlphabet[rand.IntN(len(codeAlphabet))]
+	}
+	return string(code)
+}
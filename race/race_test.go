@@ -0,0 +1,138 @@
+package race
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"monkeyy/typing"
+)
+
+func newTestHub() *Hub {
+	hub := NewHub(func() string { return "go fast" })
+	hub.Countdown = 10 * time.Millisecond
+	hub.TimeLimit = time.Minute
+	return hub
+}
+
+// waitFor reads snapshots until one matches, failing the test if none does
+// within a second.
+func waitFor(t *testing.T, m *Member, match func(Snapshot) bool) Snapshot {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case snapshot, ok := <-m.Updates:
+			if !ok {
+				t.Fatal("updates closed")
+			}
+			if match(snapshot) {
+				return snapshot
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the room to change")
+		}
+	}
+}
+
+func inState(state State) func(Snapshot) bool {
+	return func(s Snapshot) bool { return s.State == state }
+}
+
+func TestRace(t *testing.T) {
+	hub := newTestHub()
+	alice := hub.Create("s1", "alice")
+	if len(alice.Code()) != codeLength {
+		t.Fatalf("code %q, want %d characters", alice.Code(), codeLength)
+	}
+
+	bob, err := hub.Join(strings.ToLower(alice.Code()), "s2", "bob")
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	if _, err := hub.Join("????", "s3", "carol"); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("joining a missing room: err = %v", err)
+	}
+	if err := bob.Start(); !errors.Is(err, ErrNotHost) {
+		t.Errorf("bob started the race: err = %v", err)
+	}
+
+	if err := alice.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := hub.Join(alice.Code(), "s3", "carol"); !errors.Is(err, ErrRaceStarted) {
+		t.Errorf("joining during the countdown: err = %v", err)
+	}
+	waitFor(t, alice, inState(Racing))
+	snapshot := waitFor(t, bob, inState(Racing))
+	if snapshot.Text != "go fast" || snapshot.Length != 7 || len(snapshot.Players) != 2 {
+		t.Fatalf("racing snapshot = %+v", snapshot)
+	}
+
+	bob.Progress(3, 50)
+	snapshot = waitFor(t, alice, func(s Snapshot) bool { return s.Players[1].Progress == 3 })
+	if snapshot.Players[1].WPM != 50 {
+		t.Errorf("bob's WPM = %v, want 50", snapshot.Players[1].WPM)
+	}
+
+	bob.Finish(typing.Stats{NetWPM: 90, Accuracy: 100})
+	alice.Finish(typing.Stats{NetWPM: 60, Accuracy: 95})
+	snapshot = waitFor(t, alice, inState(Finished))
+	standings := snapshot.Standings()
+	if standings[0].Username != "bob" || standings[0].Place != 1 || standings[1].Username != "alice" || standings[1].Place != 2 {
+		t.Errorf("standings = %+v", standings)
+	}
+
+	if err := alice.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	snapshot = waitFor(t, bob, inState(Waiting))
+	if len(snapshot.Players) != 2 || snapshot.Players[0].Finished {
+		t.Errorf("restarted room = %+v", snapshot)
+	}
+}
+
+func TestLeavingRace(t *testing.T) {
+	hub := newTestHub()
+	alice := hub.Create("s1", "alice")
+	bob, _ := hub.Join(alice.Code(), "s2", "bob")
+
+	alice.Start()
+	waitFor(t, bob, inState(Racing))
+
+	// the host leaving mid-race hands the room over and stays in the
+	// results as not finished
+	hub.LeaveSession("s1")
+	for range alice.Updates {
+		// drained until LeaveSession closes it
+	}
+	snapshot := waitFor(t, bob, func(s Snapshot) bool { return s.Host == "s2" })
+	if !snapshot.Players[0].Left {
+		t.Errorf("alice not marked as left: %+v", snapshot.Players[0])
+	}
+
+	bob.Finish(typing.Stats{NetWPM: 70})
+	snapshot = waitFor(t, bob, inState(Finished))
+	if standings := snapshot.Standings(); standings[0].Username != "bob" || standings[1].Finished {
+		t.Errorf("standings = %+v", standings)
+	}
+
+	bob.Leave()
+	if _, err := hub.Join(alice.Code(), "s3", "carol"); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("empty room still open: err = %v", err)
+	}
+}
+
+func TestRoomFull(t *testing.T) {
+	hub := newTestHub()
+	host := hub.Create("s0", "host")
+	for i := 1; i < MaxPlayers; i++ {
+		if _, err := hub.Join(host.Code(), string(rune('a'+i)), "player"); err != nil {
+			t.Fatalf("Join %d: %v", i, err)
+		}
+	}
+	if _, err := hub.Join(host.Code(), "late", "player"); !errors.Is(err, ErrRoomFull) {
+		t.Errorf("joining a full room: err = %v", err)
+	}
+}
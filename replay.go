@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"monkeyy/data"
+	"monkeyy/typing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+var replaySpeeds = []float64{0.25, 0.5, 1, 2, 4}
+
+const defaultReplaySpeed = 2 // index of 1x in replaySpeeds
+
+type replayState struct {
+	replay *data.Replay
+	// generation tags this replay's ticks, so ticks still in flight from
+	// a replay that was closed or replaced don't start a second loop
+	generation int
+	session    *typing.Session
+	nextKey    int
+	elapsed    time.Duration
+	lastTick   time.Time
+	speedIndex int
+	paused     bool
+}
+
+type replayLoadedMsg struct {
+	replay *data.Replay
+	err    error
+}
+
+type replayTickMsg struct {
+	generation int
+	at         time.Time
+}
+
+func fetchReplayCmd(dateID string, playerID string) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in fetchReplayCmd", "panic", r, "player_id", playerID)
+			}
+		}()
+
+		log.Debug("Fetching replay", "date_id", dateID, "player_id", playerID)
+		replay, err := data.GetReplay(dateID, playerID)
+		if err != nil {
+			log.Warn("Could not fetch replay", "error", err, "date_id", dateID, "player_id", playerID)
+			return replayLoadedMsg{err: err}
+		}
+		return replayLoadedMsg{replay: replay}
+	}
+}
+
+func replayTickCmd(generation int) tea.Cmd {
+	return tea.Tick(time.Millisecond*30, func(t time.Time) tea.Msg {
+		return replayTickMsg{generation: generation, at: t}
+	})
+}
+
+func newReplayState(replay *data.Replay, generation int) *replayState {
+	r := &replayState{
+		replay:     replay,
+		generation: generation,
+		lastTick:   time.Now(),
+		speedIndex: defaultReplaySpeed,
+	}
+	r.restart()
+	return r
+}
+
+// now is the replay clock: the run's start plus the replayed time so far.
+func (r *replayState) now() time.Time {
+	return time.Unix(0, 0).Add(r.elapsed)
+}
+
+// advance moves the replay clock to now, applying every keystroke that
+// happened in the elapsed (speed-adjusted) time.
+func (r *replayState) advance(now time.Time) {
+	if !r.paused {
+		r.elapsed += time.Duration(float64(now.Sub(r.lastTick)) * replaySpeeds[r.speedIndex])
+	}
+	r.lastTick = now
+
+	for r.nextKey < len(r.replay.Keystrokes) {
+		keystroke := r.replay.Keystrokes[r.nextKey]
+		if time.Duration(keystroke.OffsetMillis)*time.Millisecond > r.elapsed {
+			break
+		}
+		r.session.Apply(time.Unix(0, 0), keystroke)
+		r.nextKey++
+	}
+}
+
+func (r *replayState) finished() bool {
+	return r.nextKey >= len(r.replay.Keystrokes)
+}
+
+func (r *replayState) restart() {
+	r.session = typing.NewSession(r.replay.Text, r.now)
+	r.nextKey = 0
+	r.elapsed = 0
+	r.paused = false
+}
+
+func updateReplay(m model, msg tea.Msg) (model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case replayTickMsg:
+		if msg.generation != m.replay.generation {
+			return m, nil
+		}
+		m.replay.advance(msg.at)
+		return m, replayTickCmd(msg.generation)
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q":
+			m.replay = nil
+			return m, nil
+		case " ", "p":
+			m.replay.paused = !m.replay.paused
+		case "+", "=", "up":
+			if m.replay.speedIndex < len(replaySpeeds)-1 {
+				m.replay.speedIndex++
+			}
+		case "-", "down":
+			if m.replay.speedIndex > 0 {
+				m.replay.speedIndex--
+			}
+		case "r":
+			m.replay.restart()
+		}
+	}
+	return m, nil
+}
+
+func renderReplay(m model) string {
+	r := m.replay
+
+	view := m
+	view.session = r.session
+	view.mode = nil
+	view.welcomeMessage = fmt.Sprintf("▶ Replay of %s's run on %s", r.replay.Username, r.replay.DateID)
+	view.WPM = int(r.session.Stats().NetWPM)
+
+	status := fmt.Sprintf("%.2fx", replaySpeeds[r.speedIndex])
+	if r.paused {
+		status += " (paused)"
+	} else if r.finished() {
+		status += " (finished)"
+	}
+
+	controlsStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	controls := controlsStyle.Render("space: pause | +/-: speed | r: restart | esc: back to leaderboard")
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		renderTypingTest(view),
+		"",
+		controlsStyle.Render("Speed: "+status),
+		controls,
+	)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"monkeyy/data"
+	"monkeyy/live"
+	"monkeyy/typing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// liveSessions holds every session connected to this server, so players can
+// watch each other type.
+var liveSessions = live.NewRegistry()
+
+// spectateState is the spectate screen: the list of players typing, or the
+// run of the one being watched. watching is nil on the list.
+type spectateState struct {
+	typists  []live.Typist
+	cursor   int
+	watching *live.Typist
+	run      live.Run
+	session  *typing.Session
+	gone     bool
+}
+
+type spectateTickMsg struct {
+	spectate *spectateState
+}
+
+func spectateTickCmd(s *spectateState) tea.Cmd {
+	return tea.Tick(time.Millisecond*100, func(t time.Time) tea.Msg {
+		return spectateTickMsg{spectate: s}
+	})
+}
+
+func openSpectate(m model) (model, tea.Cmd) {
+	m.spectate = &spectateState{}
+	refreshSpectate(m)
+	return m, spectateTickCmd(m.spectate)
+}
+
+// canWatch reports whether the player may watch run. Nobody gets to see the
+// daily challenge before they have played it.
+func canWatch(m model, run live.Run) bool {
+	return !run.DailyText || m.hasUserAlreadyDoneDailyChallenge
+}
+
+// refreshSpectate reads the latest runs from the registry.
+func refreshSpectate(m model) {
+	s := m.spectate
+	if s.watching == nil {
+		s.typists = s.typists[:0]
+		for _, typist := range liveSessions.Typing() {
+			if typist.ID != m.sessionID && canWatch(m, typist.Run) {
+				s.typists = append(s.typists, typist)
+			}
+		}
+		s.cursor = max(0, min(s.cursor, len(s.typists)-1))
+		return
+	}
+
+	run, ok := liveSessions.Watch(s.watching.ID)
+	if !ok || !canWatch(m, run) {
+		s.gone = true
+		return
+	}
+	if s.session == nil || run.Text != s.run.Text || run.Typed != s.run.Typed {
+		s.session = typing.Restore(run.Text, run.Typed)
+	}
+	s.run = run
+	s.gone = false
+}
+
+func updateSpectate(m model, msg tea.Msg) (model, tea.Cmd) {
+	s := m.spectate
+	switch msg := msg.(type) {
+	case spectateTickMsg:
+		if msg.spectate != s {
+			return m, nil
+		}
+		refreshSpectate(m)
+		return m, spectateTickCmd(s)
+
+	case tea.KeyMsg:
+		if s.watching != nil {
+			if msg.String() == "esc" || msg.String() == "q" {
+				s.watching = nil
+				s.session = nil
+				s.gone = false
+				refreshSpectate(m)
+			}
+			return m, nil
+		}
+
+		switch msg.String() {
+		case "esc", "q":
+			m.spectate = nil
+		case "up", "k":
+			if s.cursor > 0 {
+				s.cursor--
+			}
+		case "down", "j":
+			if s.cursor < len(s.typists)-1 {
+				s.cursor++
+			}
+		case "enter":
+			if len(s.typists) > 0 {
+				typist := s.typists[s.cursor]
+				s.watching = &typist
+				s.run = typist.Run
+				refreshSpectate(m)
+			}
+		}
+	}
+	return m, nil
+}
+
+// publishRun tells spectators where the player has got to in their run, or
+// that they aren't typing. The finished run stays up while its summary is on
+// screen.
+func publishRun(m model) {
+	if m.replay != nil || m.showingAdmin || m.race != nil || m.profile != nil || m.ghostMenu != nil || m.spectate != nil ||
+		!m.userSetUsername || m.mode == nil || (m.onLeaderboard() && !m.showingSummary) {
+		m.liveSession.Idle()
+		return
+	}
+	m.liveSession.Publish(m.username, live.Run{
+		Mode:      m.mode.Name,
+		DailyText: m.mode.ranked() || (m.ghost != nil && m.ghost.ghost.Mode == data.DailyMode),
+		Text:      m.session.Text(),
+		Typed:     m.session.Typed(),
+		Stats:     m.session.Stats(),
+		Finished:  m.runDone,
+	})
+}
+
+func renderSpectate(m model) string {
+	s := m.spectate
+	if s.watching != nil {
+		return renderSpectatedRun(m)
+	}
+
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Bold(true)
+	rowStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#3b82f6"))
+	detailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+
+	spectateDisplay := []string{titleStyle.Render("👀 Watch a player"), ""}
+	if len(s.typists) == 0 {
+		spectateDisplay = append(spectateDisplay, rowStyle.Render("Nobody is typing right now."))
+	}
+	for i, typist := range s.typists {
+		progress, length := typing.Restore(typist.Run.Text, typist.Run.Typed).Progress()
+		status := fmt.Sprintf("%3.0f WPM", typist.Run.Stats.NetWPM)
+		if typist.Run.Finished {
+			status += "  finished"
+		}
+		line := fmt.Sprintf(" %-*s %-18s %s %s ", raceNameColumns, typist.Username, typist.Run.Mode, progressBar(progress, length), status)
+		if i == s.cursor {
+			spectateDisplay = append(spectateDisplay, selectedStyle.Render(line))
+		} else {
+			spectateDisplay = append(spectateDisplay, rowStyle.Render(line))
+		}
+	}
+	spectateDisplay = append(spectateDisplay, "", detailStyle.Render("↑ ↓: select | enter: watch | esc: back"))
+	return lipgloss.JoinVertical(lipgloss.Left, spectateDisplay...)
+}
+
+// renderSpectatedRun shows the watched player's run the way their own
+// typing test shows it.
+func renderSpectatedRun(m model) string {
+	s := m.spectate
+	header := fmt.Sprintf("👀 Watching %s · %s", s.watching.Username, s.run.Mode)
+	if s.gone || s.session == nil {
+		detailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+		return lipgloss.JoinVertical(lipgloss.Left,
+			m.statsStyle.Render(header),
+			"",
+			fmt.Sprintf("%s isn't typing any more.", s.watching.Username),
+			"",
+			detailStyle.Render("esc: back to the list"),
+		)
+	}
+
+	stats := s.run.Stats
+	status := fmt.Sprintf("WPM: %.0f  ·  %.1f%% accuracy  ·  %d errors", stats.NetWPM, stats.Accuracy, stats.UncorrectedErrors)
+	if s.run.Finished {
+		status += "  ·  finished"
+	}
+	status += "  ·  esc: stop watching"
+	return renderTypingScreen(m, header, renderSessionText(m, s.session, -1), status)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"monkeyy/data"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+type streakLoadedMsg struct {
+	streak data.Streak
+	err    error
+}
+
+func fetchStreakCmd(playerID string) tea.Cmd {
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in fetchStreakCmd", "panic", r, "player_id", playerID)
+			}
+		}()
+
+		streak, err := data.GetStreak(playerID)
+		if err != nil {
+			log.Error("Error fetching streak", "error", err, "player_id", playerID)
+		}
+		return streakLoadedMsg{streak: streak, err: err}
+	}
+}
+
+// streakText is the short streak badge shown next to usernames, or "" for
+// players without a streak to show.
+func streakText(streak data.Streak) string {
+	if streak.Current == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" 🔥%d (best %d)", streak.Current, streak.Longest)
+}
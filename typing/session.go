@@ -0,0 +1,333 @@
+// Package typing is the typing test engine shared by the SSH UI, replays and
+// the server-side validation of submitted runs.
+package typing
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// BackspaceKey marks a backspace in a keystroke timeline.
+const BackspaceKey = "backspace"
+
+// Keystroke is one key press in a run, timed from the first keystroke.
+type Keystroke struct {
+	OffsetMillis int64  `json:"t"`
+	Key          string `json:"k"`
+}
+
+// Stats are the metrics measured for a typing run.
+type Stats struct {
+	RawWPM            float64 `json:"raw_wpm"`
+	NetWPM            float64 `json:"net_wpm"`
+	Accuracy          float64 `json:"accuracy"`
+	Keystrokes        int     `json:"keystrokes"`
+	CorrectedErrors   int     `json:"corrected_errors"`
+	UncorrectedErrors int     `json:"uncorrected_errors"`
+	ElapsedMillis     int64   `json:"elapsed_ms"`
+}
+
+// Clock tells a session what time it is.
+type Clock func() time.Time
+
+// CharState is how a character of the text stands in a session.
+type CharState int
+
+const (
+	CharPending CharState = iota
+	CharCurrent
+	CharCorrect
+	CharIncorrect
+)
+
+// Session is one attempt at typing a text. Text is compared and counted in
+// grapheme clusters, so accented letters and emoji are single characters.
+type Session struct {
+	clock      Clock
+	target     []string
+	typed      []string
+	keystrokes []Keystroke
+	start      time.Time
+	limit      time.Duration
+
+	charsTyped int
+	errorsMade int
+	corrected  int
+	lastWrong  bool
+}
+
+// NewSession starts a session for text. A nil clock uses time.Now.
+func NewSession(text string, clock Clock) *Session {
+	if clock == nil {
+		clock = time.Now
+	}
+	return &Session{
+		clock:  clock,
+		target: Graphemes(text),
+		typed:  []string{},
+	}
+}
+
+// Replay plays a recorded keystroke timeline against text and returns the
+// resulting session, with its clock stopped at the last keystroke.
+func Replay(text string, keystrokes []Keystroke) *Session {
+	start := time.Unix(0, 0)
+	s := NewSession(text, func() time.Time { return start })
+	for _, keystroke := range keystrokes {
+		s.Apply(start, keystroke)
+	}
+	if len(keystrokes) > 0 {
+		end := start.Add(time.Duration(keystrokes[len(keystrokes)-1].OffsetMillis) * time.Millisecond)
+		s.clock = func() time.Time { return end }
+	}
+	return s
+}
+
+// Restore returns a session for text with typed already typed, to show a
+// run that is being typed somewhere else. It has no keystrokes, so it reads
+// as not started and its stats are empty.
+func Restore(text string, typed string) *Session {
+	s := NewSession(text, nil)
+	s.typed = Graphemes(typed)
+	return s
+}
+
+// SetTimeLimit makes the session end once limit has passed since the first
+// keystroke, however much of the text has been typed.
+func (s *Session) SetTimeLimit(limit time.Duration) {
+	s.limit = limit
+}
+
+// TimeLeft returns how much of a timed session's limit is left.
+func (s *Session) TimeLeft() time.Duration {
+	if !s.Started() {
+		return s.limit
+	}
+	return max(0, s.limit-s.clock().Sub(s.start))
+}
+
+// TimeUp reports whether a timed session has run out of time.
+func (s *Session) TimeUp() bool {
+	return s.limit > 0 && s.Started() && s.clock().Sub(s.start) >= s.limit
+}
+
+// Extend adds more text to the end of the text being typed, for sessions
+// that run on generated text.
+func (s *Session) Extend(text string) {
+	s.target = append(s.target, Graphemes(text)...)
+}
+
+// Left returns how many characters of the text are still to be typed.
+func (s *Session) Left() int {
+	return max(0, len(s.target)-len(s.typed))
+}
+
+// Progress returns how many characters at the start of the text have been
+// typed correctly, stopping at the first mistake, and the length of the
+// text.
+func (s *Session) Progress() (int, int) {
+	correct := 0
+	for correct < len(s.typed) && correct < len(s.target) && s.typed[correct] == s.target[correct] {
+		correct++
+	}
+	return correct, len(s.target)
+}
+
+// Text returns the text being typed.
+func (s *Session) Text() string {
+	return strings.Join(s.target, "")
+}
+
+// Typed returns what has been typed so far.
+func (s *Session) Typed() string {
+	return strings.Join(s.typed, "")
+}
+
+// Keystrokes returns the keystroke timeline recorded so far.
+func (s *Session) Keystrokes() []Keystroke {
+	return s.keystrokes
+}
+
+// Started reports whether anything has been typed yet.
+func (s *Session) Started() bool {
+	return len(s.keystrokes) > 0
+}
+
+// StartTime returns when the first character was typed.
+func (s *Session) StartTime() time.Time {
+	return s.start
+}
+
+// Finished reports whether the whole text has been typed correctly, or a
+// timed session has run out of time.
+func (s *Session) Finished() bool {
+	return s.TimeUp() || s.completed()
+}
+
+func (s *Session) completed() bool {
+	return len(s.target) > 0 && s.Typed() == s.Text()
+}
+
+// Type types one character (grapheme cluster) at the given time. A lone
+// combining mark is added to the character before it. It reports whether
+// the key changed the typed text; keys past the end of the text are ignored.
+func (s *Session) Type(char string, at time.Time) bool {
+	if char == BackspaceKey || !IsTypeable(char) {
+		return false
+	}
+	return s.press(char, at)
+}
+
+// Backspace removes the last typed character, along with the line break
+// before it if one was skipped over.
+func (s *Session) Backspace() bool {
+	return s.press(BackspaceKey, s.clock())
+}
+
+// Apply replays a recorded keystroke for a run that started at start.
+func (s *Session) Apply(start time.Time, keystroke Keystroke) bool {
+	return s.press(keystroke.Key, start.Add(time.Duration(keystroke.OffsetMillis)*time.Millisecond))
+}
+
+func (s *Session) press(key string, at time.Time) bool {
+	if s.limit > 0 && s.Started() && at.Sub(s.start) >= s.limit {
+		return false
+	}
+	before := len(s.typed)
+	if !s.apply(key) {
+		return false
+	}
+
+	if len(s.keystrokes) == 0 {
+		s.start = at
+	}
+	s.keystrokes = append(s.keystrokes, Keystroke{OffsetMillis: at.Sub(s.start).Milliseconds(), Key: key})
+
+	if len(s.typed) == 0 {
+		s.lastWrong = false
+		return true
+	}
+	last := len(s.typed) - 1
+	wrong := last >= len(s.target) || s.typed[last] != s.target[last]
+
+	switch {
+	case key == BackspaceKey:
+	case IsCombiningMark(key):
+		// A combining mark finishes the character before it, so it can turn
+		// an error into a correct character or the other way round.
+		if s.lastWrong && !wrong {
+			s.errorsMade = max(0, s.errorsMade-1)
+		} else if !s.lastWrong && wrong {
+			s.errorsMade++
+		}
+	case before < len(s.target):
+		s.charsTyped++
+		if wrong {
+			s.errorsMade++
+		}
+	}
+	s.lastWrong = wrong
+	return true
+}
+
+// apply changes the typed text for key, returning false if the key has no
+// effect.
+func (s *Session) apply(key string) bool {
+	if key == BackspaceKey {
+		if len(s.typed) == 0 {
+			return false
+		}
+		last := len(s.typed) - 1
+		if last >= len(s.target) || s.typed[last] != s.target[last] {
+			s.corrected++
+		}
+		s.typed = s.typed[:last]
+		if len(s.typed) > 0 && s.typed[len(s.typed)-1] == "\n" {
+			s.typed = s.typed[:len(s.typed)-1]
+		}
+		return true
+	}
+
+	if IsCombiningMark(key) {
+		if len(s.typed) == 0 {
+			return false
+		}
+		s.typed = Graphemes(s.Typed() + key)
+		return true
+	}
+
+	if len(s.typed) >= len(s.target) {
+		return false
+	}
+	if s.target[len(s.typed)] == "\n" {
+		s.typed = append(s.typed, "\n")
+		if len(s.typed) >= len(s.target) {
+			return true
+		}
+	}
+	s.typed = Graphemes(s.Typed() + key)
+	return true
+}
+
+// Chars returns the characters of the text with how each one stands: typed
+// correctly, typed wrong, the one to type next, or still to come. Everything
+// typed after the first mistake is marked wrong. When the next character is
+// a line break, the character after it is the current one.
+func (s *Session) Chars() ([]string, []CharState) {
+	states := make([]CharState, len(s.target))
+	foundError := false
+	for i := range s.target {
+		switch {
+		case i < len(s.typed):
+			if foundError || s.typed[i] != s.target[i] {
+				foundError = true
+				states[i] = CharIncorrect
+			} else {
+				states[i] = CharCorrect
+			}
+		case i == len(s.typed):
+			states[i] = CharCurrent
+		case i == len(s.typed)+1 && s.target[i-1] == "\n":
+			states[i] = CharCurrent
+		}
+	}
+	return s.target, states
+}
+
+// Stats measures the run from the first keystroke to the last one once the
+// text is finished, or to now while it is still being typed. Timed sessions
+// are measured over their whole time limit once it runs out. Raw WPM counts
+// every character typed, net WPM only what is left correct in the text,
+// penalised by uncorrected errors.
+func (s *Session) Stats() Stats {
+	correct, uncorrected := 0, 0
+	for i, char := range s.typed {
+		if i < len(s.target) && char == s.target[i] {
+			correct++
+		} else {
+			uncorrected++
+		}
+	}
+
+	stats := Stats{
+		Keystrokes:        len(s.keystrokes),
+		CorrectedErrors:   s.corrected,
+		UncorrectedErrors: uncorrected,
+	}
+	if s.TimeUp() {
+		stats.ElapsedMillis = s.limit.Milliseconds()
+	} else if s.completed() && len(s.keystrokes) > 0 {
+		stats.ElapsedMillis = s.keystrokes[len(s.keystrokes)-1].OffsetMillis
+	} else if s.Started() {
+		stats.ElapsedMillis = s.clock().Sub(s.start).Milliseconds()
+	}
+	if s.charsTyped > 0 {
+		stats.Accuracy = 100 * float64(s.charsTyped-s.errorsMade) / float64(s.charsTyped)
+	}
+	if minutes := (time.Duration(stats.ElapsedMillis) * time.Millisecond).Minutes(); minutes > 0 {
+		stats.RawWPM = float64(s.charsTyped) / 5.0 / minutes
+		stats.NetWPM = math.Max(0, (float64(correct)/5.0-float64(uncorrected))/minutes)
+	}
+	return stats
+}
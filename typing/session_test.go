@@ -0,0 +1,271 @@
+package typing
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+// fakeClock is a clock tests move forward by hand.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// typeKeys types keys 100ms apart, treating BackspaceKey as a backspace.
+func typeKeys(text string, keys ...string) (*Session, *fakeClock) {
+	clock := &fakeClock{now: start}
+	s := NewSession(text, clock.Now)
+	for _, key := range keys {
+		if key == BackspaceKey {
+			s.Backspace()
+		} else {
+			s.Type(key, clock.now)
+		}
+		clock.now = clock.now.Add(100 * time.Millisecond)
+	}
+	clock.now = clock.now.Add(-100 * time.Millisecond)
+	return s, clock
+}
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"ascii", "abc", []string{"a", "b", "c"}},
+		{"precomposed accent", "café", []string{"c", "a", "f", "é"}},
+		{"decomposed accent is normalized", "café", []string{"c", "a", "f", "é"}},
+		{"combining mark without precomposed form", "q̇x", []string{"q̇", "x"}},
+		{"emoji", "hi 👋", []string{"h", "i", " ", "👋"}},
+		{"zwj sequence", "👩‍💻!", []string{"👩‍💻", "!"}},
+		{"flag", "🇯🇵a", []string{"🇯🇵", "a"}},
+		{"newline", "a\nb", []string{"a", "\n", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Graphemes(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Graphemes(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionTyping(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		keys     []string
+		want     string
+		finished bool
+	}{
+		{"ascii", "abc", []string{"a", "b", "c"}, "abc", true},
+		{"unfinished", "abc", []string{"a", "b"}, "ab", false},
+		{"mistake left in", "abc", []string{"a", "x", "c"}, "axc", false},
+		{"accented character", "né", []string{"n", "é"}, "né", true},
+		{"combining mark joins previous character", "né", []string{"n", "e", "́"}, "né", true},
+		{"leading combining mark is ignored", "é", []string{"́", "é"}, "é", true},
+		{"missing accent", "café", []string{"c", "a", "f", "e"}, "cafe", false},
+		{"backspace removes accented character", "né", []string{"n", "é", BackspaceKey}, "n", false},
+		{"backspace removes whole emoji", "a👩‍💻", []string{"a", "👩‍💻", BackspaceKey}, "a", false},
+		{"emoji", "ok 👍🏽", []string{"o", "k", " ", "👍🏽"}, "ok 👍🏽", true},
+		{"partial emoji sequence", "👩‍💻", []string{"👩"}, "👩", false},
+		{"typing past the end is ignored", "é", []string{"é", "x"}, "é", true},
+		{"backspace on empty text", "ab", []string{BackspaceKey, "a"}, "a", false},
+		{"newline is skipped", "é\nb", []string{"é", "b"}, "é\nb", true},
+		{"backspace removes skipped newline", "é\nb", []string{"é", "b", BackspaceKey}, "é", false},
+		{"multi-character key is ignored", "abc", []string{"ab", "a"}, "a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := typeKeys(tt.text, tt.keys...)
+			if got := s.Typed(); got != tt.want {
+				t.Errorf("typed %q, want %q", got, tt.want)
+			}
+			if got := s.Finished(); got != tt.finished {
+				t.Errorf("Finished() = %v, want %v", got, tt.finished)
+			}
+		})
+	}
+}
+
+func TestSessionStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		keys        []string
+		chars       int
+		accuracy    float64
+		corrected   int
+		uncorrected int
+	}{
+		{"perfect run", "abc", []string{"a", "b", "c"}, 3, 100, 0, 0},
+		{"corrected mistake", "abc", []string{"a", "x", BackspaceKey, "b", "c"}, 4, 75, 1, 0},
+		{"uncorrected mistake", "abcd", []string{"a", "x", "c"}, 3, 200.0 / 3, 0, 1},
+		{"accented text", "déjà vu", []string{"d", "é", "j", "à", " ", "v", "u"}, 7, 100, 0, 0},
+		{"combining marks", "déjà", []string{"d", "e", "́", "j", "a", "̀"}, 4, 100, 0, 0},
+		{"wrong combining mark", "déjàx", []string{"d", "e", "̀", "j", "à"}, 4, 75, 0, 1},
+		{"emoji counts as one character", "hi 👋🏽", []string{"h", "i", " ", "👋🏽"}, 4, 100, 0, 0},
+		{"corrected emoji", "🙂!", []string{"🙃", BackspaceKey, "🙂", "!"}, 3, 200.0 / 3, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := typeKeys(tt.text, tt.keys...)
+			stats := s.Stats()
+			if stats.Keystrokes != len(tt.keys) {
+				t.Errorf("keystrokes = %d, want %d", stats.Keystrokes, len(tt.keys))
+			}
+			if stats.Accuracy != tt.accuracy {
+				t.Errorf("accuracy = %.2f, want %.2f", stats.Accuracy, tt.accuracy)
+			}
+			if stats.CorrectedErrors != tt.corrected || stats.UncorrectedErrors != tt.uncorrected {
+				t.Errorf("errors = %d corrected, %d uncorrected, want %d, %d", stats.CorrectedErrors, stats.UncorrectedErrors, tt.corrected, tt.uncorrected)
+			}
+			wantElapsed := int64(len(tt.keys)-1) * 100
+			if stats.ElapsedMillis != wantElapsed {
+				t.Errorf("elapsed = %dms, want %dms", stats.ElapsedMillis, wantElapsed)
+			}
+			wantRaw := float64(tt.chars) / 5.0 / (float64(wantElapsed) / 60000)
+			if stats.RawWPM != wantRaw {
+				t.Errorf("raw WPM = %.2f, want %.2f (%d characters)", stats.RawWPM, wantRaw, tt.chars)
+			}
+		})
+	}
+}
+
+func TestSessionStatsUseClockUntilFinished(t *testing.T) {
+	s, clock := typeKeys("hello world", "h", "e", "l", "l", "o")
+	clock.now = start.Add(time.Minute)
+	if got := s.Stats().ElapsedMillis; got != time.Minute.Milliseconds() {
+		t.Errorf("elapsed while typing = %dms, want %dms", got, time.Minute.Milliseconds())
+	}
+	if got := s.Stats().NetWPM; got != 1 {
+		t.Errorf("net WPM = %.2f, want 1", got)
+	}
+
+	for _, char := range " world" {
+		s.Type(string(char), clock.now)
+	}
+	clock.now = clock.now.Add(time.Hour)
+	if got := s.Stats().ElapsedMillis; got != time.Minute.Milliseconds() {
+		t.Errorf("elapsed after finishing = %dms, want %dms", got, time.Minute.Milliseconds())
+	}
+}
+
+func TestSessionChars(t *testing.T) {
+	const (
+		P  = CharPending
+		C  = CharCurrent
+		OK = CharCorrect
+		X  = CharIncorrect
+	)
+	tests := []struct {
+		name     string
+		text     string
+		keys     []string
+		want     []CharState
+		progress int
+	}{
+		{"nothing typed", "abc", nil, []CharState{C, P, P}, 0},
+		{"correct so far", "abc", []string{"a"}, []CharState{OK, C, P}, 1},
+		{"everything after a mistake is wrong", "abcd", []string{"x", "b"}, []CharState{X, X, C, P}, 0},
+		{"progress stops at a mistake", "abcd", []string{"a", "x", "c"}, []CharState{OK, X, X, C}, 1},
+		{"line break highlights next line", "ab\ncd", []string{"a", "b"}, []CharState{OK, OK, C, C, P}, 2},
+		{"finished", "é👍", []string{"é", "👍"}, []CharState{OK, OK}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := typeKeys(tt.text, tt.keys...)
+			_, got := s.Chars()
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Chars() = %v, want %v", got, tt.want)
+			}
+			if progress, total := s.Progress(); progress != tt.progress || total != len(tt.want) {
+				t.Errorf("Progress() = %d of %d, want %d of %d", progress, total, tt.progress, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestReplayMatchesLiveSession(t *testing.T) {
+	keys := []string{"c", "r", "e", "̀", "m", "x", BackspaceKey, "e", " ", "🍮"}
+	live, _ := typeKeys("crème 🍮", keys...)
+	replayed := Replay("crème 🍮", live.Keystrokes())
+
+	if replayed.Typed() != live.Typed() || !replayed.Finished() {
+		t.Fatalf("replay typed %q, live typed %q", replayed.Typed(), live.Typed())
+	}
+	if replayed.Stats() != live.Stats() {
+		t.Errorf("replay stats %+v, live stats %+v", replayed.Stats(), live.Stats())
+	}
+}
+
+func TestRestoreMatchesLiveSession(t *testing.T) {
+	live, _ := typeKeys("crème\n🍮", "c", "r", "x", "̀", BackspaceKey, "è", "m", "e", " ")
+	restored := Restore(live.Text(), live.Typed())
+
+	wantChars, wantStates := live.Chars()
+	chars, states := restored.Chars()
+	if !slices.Equal(chars, wantChars) || !slices.Equal(states, wantStates) {
+		t.Errorf("restored chars %q %v, live chars %q %v", chars, states, wantChars, wantStates)
+	}
+	if restored.Started() {
+		t.Error("restored session reads as started")
+	}
+}
+
+func TestRestoreFinished(t *testing.T) {
+	restored := Restore("abc", "abc")
+	if !restored.Finished() {
+		t.Error("fully typed restored session is not finished")
+	}
+	if stats := restored.Stats(); stats.ElapsedMillis != 0 || stats.NetWPM != 0 || stats.Keystrokes != 0 {
+		t.Errorf("restored stats = %+v, want them empty", stats)
+	}
+}
+
+func TestSessionTimeLimit(t *testing.T) {
+	clock := &fakeClock{now: start}
+	s := NewSession("the quick brown fox", clock.Now)
+	s.SetTimeLimit(15 * time.Second)
+
+	if got := s.TimeLeft(); got != 15*time.Second {
+		t.Errorf("TimeLeft() before starting = %v, want 15s", got)
+	}
+	for _, char := range "the " {
+		s.Type(string(char), clock.now)
+		clock.now = clock.now.Add(time.Second)
+	}
+	if s.Finished() {
+		t.Fatal("session finished before its time limit")
+	}
+	if got := s.TimeLeft(); got != 11*time.Second {
+		t.Errorf("TimeLeft() = %v, want 11s", got)
+	}
+
+	s.Extend(" jumps over")
+	if got := s.Left(); got != len("quick brown fox jumps over") {
+		t.Errorf("Left() after Extend = %d, want %d", got, len("quick brown fox jumps over"))
+	}
+
+	clock.now = start.Add(15 * time.Second)
+	if !s.Finished() || !s.TimeUp() {
+		t.Fatal("session not finished once its time limit passed")
+	}
+	if s.Type("q", clock.now) {
+		t.Error("typed after the time limit")
+	}
+	stats := s.Stats()
+	if stats.ElapsedMillis != 15000 {
+		t.Errorf("elapsed = %dms, want the 15s limit", stats.ElapsedMillis)
+	}
+	if stats.NetWPM != 3.2 {
+		t.Errorf("net WPM = %.2f, want 3.2", stats.NetWPM)
+	}
+}
@@ -0,0 +1,56 @@
+package typing
+
+import (
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeText puts text in NFC form so that precomposed and decomposed
+// spellings of the same character compare equal.
+func NormalizeText(s string) string {
+	return norm.NFC.String(s)
+}
+
+// Graphemes splits normalized text into user-perceived characters (grapheme
+// clusters), the unit the typing engine compares and counts in.
+func Graphemes(s string) []string {
+	clusters := []string{}
+	state := -1
+	rest := NormalizeText(s)
+	for len(rest) > 0 {
+		var cluster string
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// IsCombiningMark reports whether key is a lone combining mark, which is
+// typed onto the previous character instead of taking a position of its own.
+func IsCombiningMark(key string) bool {
+	for _, r := range key {
+		if !unicode.Is(unicode.M, r) {
+			return false
+		}
+	}
+	return key != ""
+}
+
+// IsTypeable reports whether key is a single character the typing screen
+// accepts.
+func IsTypeable(key string) bool {
+	if IsCombiningMark(key) {
+		return true
+	}
+	if uniseg.GraphemeClusterCount(key) != 1 {
+		return false
+	}
+	for _, r := range key {
+		if unicode.IsControl(r) && r != '\t' {
+			return false
+		}
+	}
+	return true
+}